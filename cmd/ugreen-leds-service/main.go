@@ -12,12 +12,18 @@ import (
 	"syscall"
 
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/control"
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/diskmon"
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/netmon"
 )
 
 var (
-	configFile = flag.String("config", "/etc/ugreen-leds.conf", "Path to configuration file")
+	configFile      = flag.String("config", "/etc/ugreen-leds.conf", "Path to configuration file")
+	identify        = flag.String("identify", "", "Blink the bay of a disk, given by device, serial, WWN or zpool vdev, in the running service")
+	identifyTimeout = flag.String("identify-timeout", "", "How long -identify blinks, e.g. 90s or 10m (default IDENTIFY_TIMEOUT)")
+	clearIdentify   = flag.String("clear-identify", "", "Stop identifying a disk in the running service, or \"all\" disks")
+	faults          = flag.String("faults", "", "Print the fault history of a disk, or of \"all\" disks, from the running service")
+	ack             = flag.String("ack", "", "Acknowledge the latched faults of a disk or slot, or of \"all\" disks, in the running service")
 )
 
 func main() {
@@ -31,6 +37,14 @@ func main() {
 		cfg.SetDefaults()
 	}
 
+	// Send commands to the running service instead of starting one
+	if args := controlCommand(); args != nil {
+		if err := sendCommand(cfg.DiskMonitor.ControlSocket, args); err != nil {
+			log.Fatalf("Failed to send command: %v", err)
+		}
+		return
+	}
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -80,8 +94,51 @@ func main() {
 	log.Println("Service stopped")
 }
 
+// controlCommand returns the command given by the command flags, or nil if
+// the service should run
+func controlCommand() []string {
+	// withTarget appends a disk to a command, "all" meaning every disk
+	withTarget := func(command, target string) []string {
+		if target == "all" {
+			return []string{command}
+		}
+		return []string{command, target}
+	}
+
+	switch {
+	case *identify != "":
+		args := []string{"identify", *identify}
+		if *identifyTimeout != "" {
+			args = append(args, *identifyTimeout)
+		}
+		return args
+	case *clearIdentify != "":
+		return withTarget("clear", *clearIdentify)
+	case *faults != "":
+		return withTarget("faults", *faults)
+	case *ack != "":
+		return withTarget("ack", *ack)
+	}
+	return nil
+}
+
+// sendCommand sends a command to the control socket of the running service
+// and prints its reply
+func sendCommand(socket string, args []string) error {
+	if socket == "" {
+		return fmt.Errorf("the control socket is disabled in %s", *configFile)
+	}
+
+	reply, err := control.Send(socket, args...)
+	if err != nil {
+		return err
+	}
+	fmt.Print(reply)
+	return nil
+}
+
 func ensureKernelModules() error {
-	modules := []string{"ledtrig_oneshot", "ledtrig_netdev"}
+	modules := []string{"ledtrig_oneshot", "ledtrig_netdev", "ledtrig_timer"}
 	for _, mod := range modules {
 		if err := loadKernelModule(mod); err != nil {
 			return fmt.Errorf("failed to load %s: %w", mod, err)
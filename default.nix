@@ -12,6 +12,8 @@
   dmidecode,
   util-linux,
   smartmontools,
+  nvme-cli,
+  btrfs-progs,
   zfs,
   iproute2,
 }:
@@ -124,6 +126,8 @@ let
     # Runtime dependencies
     propagatedBuildInputs = [
       smartmontools
+      nvme-cli
+      btrfs-progs
       zfs
       iproute2
       util-linux
@@ -0,0 +1,156 @@
+// Package blockdev resolves block devices through partitions and device
+// mapper, md and other stacked devices to the physical disks below them.
+package blockdev
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// devDirs are searched for device names given without a path
+var devDirs = []string{
+	"/dev",
+	"/dev/disk/by-id",
+	"/dev/disk/by-path",
+	"/dev/disk/by-vdev",
+	"/dev/disk/by-partuuid",
+	"/dev/mapper",
+}
+
+var (
+	sdPartition   = regexp.MustCompile(`^((?:sd|vd|hd|xvd)[a-z]+)\d*$`)
+	nvmePartition = regexp.MustCompile(`^((?:nvme\d+n|mmcblk)\d+)(?:p\d+)?$`)
+)
+
+// PhysicalDisks returns the kernel names of the disks below a device node,
+// such as [sda] for /dev/disk/by-id/ata-...-part1 or [sda sdb] for an LVM
+// volume spanning both. Names without a directory are looked up in the
+// usual /dev directories. root is the filesystem root, empty for the host.
+func PhysicalDisks(root, path string) []string {
+	name := KernelName(root, path)
+	if name == "" {
+		return nil
+	}
+	return Disks(root, name)
+}
+
+// KernelName returns the kernel name of a device node, following symlinks
+// such as /dev/mapper/crypt-sda or /dev/disk/by-id/... It returns the base
+// name of path if the node does not exist.
+func KernelName(root, path string) string {
+	if path == "" {
+		return ""
+	}
+
+	candidates := []string{path}
+	if !strings.HasPrefix(path, "/") {
+		candidates = candidates[:0]
+		for _, dir := range devDirs {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+	for _, candidate := range candidates {
+		if resolved, ok := followLinks(root, candidate); ok {
+			return filepath.Base(resolved)
+		}
+	}
+	return filepath.Base(path)
+}
+
+// Disks returns the physical disks below a block device given by its kernel
+// name. Partitions resolve to their disk, and stacked devices such as dm-3
+// or md0 to the disks of their slaves. A disk that is gone from sysfs goes
+// by its name, so that sdb1 still resolves to sdb.
+func Disks(root, name string) []string {
+	seen := make(map[string]bool)
+	var disks []string
+	var walk func(name string, depth int)
+	walk = func(name string, depth int) {
+		// Stacks are shallow, this only guards against sysfs loops
+		if depth > 16 {
+			return
+		}
+
+		if parent := partitionParent(root, name); parent != "" {
+			walk(parent, depth+1)
+			return
+		}
+
+		dir := sysBlock(root, name)
+		if _, err := os.Stat(dir); err != nil {
+			if disk := diskByName(name); disk != "" && !seen[disk] {
+				seen[disk] = true
+				disks = append(disks, disk)
+			}
+			return
+		}
+
+		slaves, _ := os.ReadDir(filepath.Join(dir, "slaves"))
+		if len(slaves) == 0 {
+			if !seen[name] {
+				seen[name] = true
+				disks = append(disks, name)
+			}
+			return
+		}
+		for _, slave := range slaves {
+			walk(slave.Name(), depth+1)
+		}
+	}
+	walk(name, 0)
+
+	sort.Strings(disks)
+	return disks
+}
+
+// partitionParent returns the device a partition belongs to, or "" if name
+// is not a partition. Partitions live in the sysfs directory of their disk.
+func partitionParent(root, name string) string {
+	matches, _ := filepath.Glob(sysBlock(root, "*", name, "partition"))
+	if len(matches) == 0 {
+		return ""
+	}
+	return filepath.Base(filepath.Dir(filepath.Dir(matches[0])))
+}
+
+// sysBlock returns a path below /sys/block, relative to the filesystem root
+func sysBlock(root string, elem ...string) string {
+	return filepath.Join(append([]string{"/", root, "sys", "block"}, elem...)...)
+}
+
+// diskByName guesses the disk of a device that is not in sysfs
+func diskByName(name string) string {
+	if match := sdPartition.FindStringSubmatch(name); match != nil {
+		return match[1]
+	}
+	if match := nvmePartition.FindStringSubmatch(name); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// followLinks resolves symlinks below root, including dangling ones. It
+// reports whether path exists as a file or a link.
+func followLinks(root, path string) (string, bool) {
+	for i := 0; i < 16; i++ {
+		info, err := os.Lstat(filepath.Join(root, path))
+		if err != nil {
+			return path, i > 0
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return path, true
+		}
+		target, err := os.Readlink(filepath.Join(root, path))
+		if err != nil {
+			return path, true
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return path, true
+}
@@ -0,0 +1,142 @@
+package blockdev
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func mkdir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+	mkdir(t, filepath.Dir(path))
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+}
+
+// buildFakeStack creates a sysfs and /dev tree below root with
+//
+//	sda, sdb, sdc, nvme0n1   physical disks
+//	dm-0 (crypt-a)           LUKS on sda1
+//	dm-1 (crypt-b)           LUKS on sdb1
+//	md0                      RAID 1 of sdc1 and nvme0n1p2, partitioned into md0p1
+//	dm-2 (vg-data)           LVM volume on dm-1 and md0p1
+func buildFakeStack(t *testing.T, root string) {
+	t.Helper()
+	sysBlock := filepath.Join(root, "sys", "block")
+	partitions := map[string][]string{
+		"sda":     {"sda1"},
+		"sdb":     {"sdb1"},
+		"sdc":     {"sdc1"},
+		"nvme0n1": {"nvme0n1p1", "nvme0n1p2"},
+		"md0":     {"md0p1"},
+	}
+	for disk, parts := range partitions {
+		for _, part := range parts {
+			mkdir(t, filepath.Join(sysBlock, disk, part))
+			if err := os.WriteFile(filepath.Join(sysBlock, disk, part, "partition"), []byte("1\n"), 0644); err != nil {
+				t.Fatalf("Failed to write partition: %v", err)
+			}
+		}
+	}
+	slaves := map[string][]string{
+		"dm-0": {"sda1"},
+		"dm-1": {"sdb1"},
+		"md0":  {"sdc1", "nvme0n1p2"},
+		"dm-2": {"dm-1", "md0p1"},
+	}
+	for dev, devSlaves := range slaves {
+		mkdir(t, filepath.Join(sysBlock, dev, "slaves"))
+		for _, slave := range devSlaves {
+			symlink(t, "../../"+slave, filepath.Join(sysBlock, dev, "slaves", slave))
+		}
+	}
+
+	symlink(t, "../dm-0", filepath.Join(root, "dev", "mapper", "crypt-a"))
+	symlink(t, "../dm-1", filepath.Join(root, "dev", "mapper", "crypt-b"))
+	symlink(t, "../dm-2", filepath.Join(root, "dev", "mapper", "vg-data"))
+	symlink(t, "../../sda", filepath.Join(root, "dev", "disk", "by-id", "ata-WDC_WD40EFRX-68N32N0_WD-WCC7K1234567"))
+	symlink(t, "../../sdb1", filepath.Join(root, "dev", "disk", "by-id", "ata-ST4000VN008-2DR166_ZL2ABCDE-part1"))
+	symlink(t, "../../nvme0n1p2", filepath.Join(root, "dev", "disk", "by-partuuid", "0b4c2d1e-02"))
+}
+
+func TestPhysicalDisks(t *testing.T) {
+	root := t.TempDir()
+	buildFakeStack(t, root)
+
+	tests := map[string][]string{
+		"":                    nil,
+		"/dev/sda":            {"sda"},
+		"/dev/sda1":           {"sda"},
+		"sdb1":                {"sdb"},
+		"/dev/nvme0n1p2":      {"nvme0n1"},
+		"0b4c2d1e-02":         {"nvme0n1"},
+		"/dev/dm-0":           {"sda"},
+		"/dev/mapper/crypt-a": {"sda"},
+		"crypt-b":             {"sdb"},
+		"/dev/md0":            {"nvme0n1", "sdc"},
+		"/dev/md0p1":          {"nvme0n1", "sdc"},
+		"/dev/mapper/vg-data": {"nvme0n1", "sdb", "sdc"},
+		"4839211982734645123": nil,
+		// Devices of disks that are gone go by their name
+		"/dev/sdx3":      {"sdx"},
+		"/dev/nvme3n1p1": {"nvme3n1"},
+		"/dev/disk/by-id/ata-WDC_WD40EFRX-68N32N0_WD-WCC7K1234567": {"sda"},
+		"ata-ST4000VN008-2DR166_ZL2ABCDE-part1":                    {"sdb"},
+	}
+	for path, expected := range tests {
+		if result := PhysicalDisks(root, path); !reflect.DeepEqual(result, expected) {
+			t.Errorf("PhysicalDisks(%q) = %v, want %v", path, result, expected)
+		}
+	}
+}
+
+func TestKernelName(t *testing.T) {
+	root := t.TempDir()
+	buildFakeStack(t, root)
+
+	tests := map[string]string{
+		"":                    "",
+		"/dev/mapper/vg-data": "dm-2",
+		"crypt-a":             "dm-0",
+		"/dev/sdq1":           "sdq1",
+	}
+	for path, expected := range tests {
+		if result := KernelName(root, path); result != expected {
+			t.Errorf("KernelName(%q) = %q, want %q", path, result, expected)
+		}
+	}
+}
+
+func TestDisks_HostRoot(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	// A sysfs tree below the working directory must not be mistaken for
+	// the host's
+	dir := t.TempDir()
+	mkdir(t, filepath.Join(dir, "sys", "block", "ugreen-stack", "slaves", "ugreen-disk"))
+	mkdir(t, filepath.Join(dir, "sys", "block", "ugreen-disk", "ugreen-part"))
+	if err := os.WriteFile(filepath.Join(dir, "sys", "block", "ugreen-disk", "ugreen-part", "partition"), []byte("1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write partition: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	defer os.Chdir(wd)
+
+	for _, name := range []string{"ugreen-stack", "ugreen-part"} {
+		if result := Disks("", name); result != nil {
+			t.Errorf("Disks(%q) = %v, want nil from the host's /sys/block", name, result)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when a test advances it. Tickers and
+// timers fire as the time passes their deadlines, dropping ticks a slow
+// receiver missed like their time package counterparts.
+type Fake struct {
+	mu      sync.Mutex
+	changed *sync.Cond // signalled when a ticker or timer starts or stops
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFake returns a fake clock set to now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the time of the fake clock
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// NewTicker returns a ticker firing every d of fake time
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.start(d, d)}
+}
+
+// NewTimer returns a timer firing after d of fake time
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.start(d, 0)
+}
+
+// Advance moves the clock forward by d, firing every ticker and timer whose
+// deadline it passes, in order
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := f.now.Add(d)
+	for {
+		var next *fakeWaiter
+		for _, w := range f.waiters {
+			if !w.deadline.After(end) && (next == nil || w.deadline.Before(next.deadline)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+		f.now = next.deadline
+		next.fire()
+	}
+	f.now = end
+}
+
+// BlockUntil waits until n tickers and timers are running, so that a test
+// can advance the clock once the loop it started is waiting
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) < n {
+		f.changed.Wait()
+	}
+}
+
+func (f *Fake) start(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{clock: f, c: make(chan time.Time, 1), period: period}
+	w.schedule(d)
+	return w
+}
+
+// fakeTicker adapts a fakeWaiter to the Ticker interface
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+// fakeWaiter is a ticker, or a timer if it has no period
+type fakeWaiter struct {
+	clock    *Fake
+	c        chan time.Time
+	period   time.Duration
+	deadline time.Time
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	return w.remove()
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	active := w.remove()
+	w.schedule(d)
+	return active
+}
+
+// fire delivers a tick and schedules the next one. The caller must hold the
+// clock's lock.
+func (w *fakeWaiter) fire() {
+	select {
+	case w.c <- w.clock.now:
+	default:
+	}
+	if w.period > 0 {
+		w.deadline = w.deadline.Add(w.period)
+	} else {
+		w.remove()
+	}
+}
+
+// schedule starts the waiter. The caller must hold the clock's lock.
+func (w *fakeWaiter) schedule(d time.Duration) {
+	w.deadline = w.clock.now.Add(d)
+	w.clock.waiters = append(w.clock.waiters, w)
+	w.clock.changed.Broadcast()
+}
+
+// remove stops the waiter and reports whether it was running. The caller
+// must hold the clock's lock.
+func (w *fakeWaiter) remove() bool {
+	for i, other := range w.clock.waiters {
+		if other == w {
+			w.clock.waiters = append(w.clock.waiters[:i], w.clock.waiters[i+1:]...)
+			w.clock.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
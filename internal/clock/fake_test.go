@@ -0,0 +1,96 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFake_Ticker(t *testing.T) {
+	f := NewFake(epoch)
+	ticker := f.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	f.Advance(59 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before its interval")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(epoch.Add(time.Minute)) {
+			t.Errorf("tick = %v, want %v", tick, epoch.Add(time.Minute))
+		}
+	default:
+		t.Fatal("ticker did not fire after its interval")
+	}
+
+	// Ticks nobody received are dropped, leaving the first one waiting
+	f.Advance(3 * time.Minute)
+	if tick := <-ticker.C(); !tick.Equal(epoch.Add(2 * time.Minute)) {
+		t.Errorf("tick = %v, want %v", tick, epoch.Add(2*time.Minute))
+	}
+	select {
+	case <-ticker.C():
+		t.Error("ticker delivered more than one missed tick")
+	default:
+	}
+	if now := f.Now(); !now.Equal(epoch.Add(4 * time.Minute)) {
+		t.Errorf("Now() = %v, want %v", now, epoch.Add(4*time.Minute))
+	}
+
+	ticker.Stop()
+	f.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Error("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFake_Timer(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(time.Second)
+
+	f.Advance(time.Second)
+	<-timer.C()
+	f.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Error("timer fired twice")
+	default:
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("Reset() of an expired timer = true, want false")
+	}
+	if !timer.Stop() {
+		t.Error("Stop() of a running timer = false, want true")
+	}
+	f.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Error("stopped timer fired")
+	default:
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	ticks := make(chan time.Time)
+	go func() {
+		ticker := f.NewTicker(time.Second)
+		defer ticker.Stop()
+		ticks <- <-ticker.C()
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	if tick := <-ticks; !tick.Equal(epoch.Add(time.Second)) {
+		t.Errorf("tick = %v, want %v", tick, epoch.Add(time.Second))
+	}
+}
@@ -0,0 +1,126 @@
+// Package command runs the external tools the monitors depend on, such as
+// smartctl, zpool and ping, with a time limit per tool. A tool that hangs,
+// like zpool status during a stuck pool import, is killed with its whole
+// process group instead of blocking a monitor loop or shutdown.
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrTimeout is returned, wrapped in an *Error, when a tool ran out of time
+var ErrTimeout = errors.New("timed out")
+
+// DefaultTimeout limits tools without a timeout of their own
+const DefaultTimeout = 30 * time.Second
+
+// waitDelay is how long to wait for the output of a killed process group,
+// in case a process outside the group holds the pipes open
+const waitDelay = time.Second
+
+// Timeouts are the default time limits of the tools the monitors run
+var Timeouts = map[string]time.Duration{
+	"smartctl":  30 * time.Second,
+	"nvme":      30 * time.Second,
+	"zpool":     30 * time.Second,
+	"btrfs":     30 * time.Second,
+	"lsblk":     10 * time.Second,
+	"dmidecode": 10 * time.Second,
+	"ip":        5 * time.Second,
+	"ping":      5 * time.Second,
+}
+
+// Error is a failed run of a tool
+type Error struct {
+	Name   string   // tool name, e.g. zpool
+	Args   []string // arguments
+	Stderr string   // standard error, trimmed
+	Err    error    // ErrTimeout, an *exec.ExitError or the reason it did not start
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %v", strings.Join(append([]string{e.Name}, e.Args...), " "), e.Err)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsTimeout reports whether err is a tool running out of time
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// Runner runs external tools
+type Runner interface {
+	// Output runs a tool and returns its standard output
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// Exec runs tools on the host
+type Exec struct {
+	Timeouts map[string]time.Duration // tool name -> time limit, overriding the defaults
+}
+
+// NewExec returns a runner with the configured timeouts, in seconds
+func NewExec(seconds map[string]int) *Exec {
+	timeouts := make(map[string]time.Duration, len(seconds))
+	for name, s := range seconds {
+		timeouts[name] = time.Duration(s) * time.Second
+	}
+	return &Exec{Timeouts: timeouts}
+}
+
+// Timeout returns the time limit of a tool
+func (e *Exec) Timeout(name string) time.Duration {
+	base := filepath.Base(name)
+	if t, ok := e.Timeouts[base]; ok && t > 0 {
+		return t
+	}
+	if t, ok := Timeouts[base]; ok {
+		return t
+	}
+	return DefaultTimeout
+}
+
+// Output runs a tool and returns its standard output. The tool is killed
+// with its process group when ctx is done or its time limit passes. Output
+// is returned along with an *exec.ExitError, as some tools (smartctl) report
+// through their exit status.
+func (e *Exec) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout(name))
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = waitDelay
+
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = ErrTimeout
+	} else if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return stdout.Bytes(), &Error{Name: name, Args: args, Stderr: strings.TrimSpace(stderr.String()), Err: err}
+}
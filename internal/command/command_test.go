@@ -0,0 +1,81 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestExec_Output(t *testing.T) {
+	e := &Exec{}
+	output, err := e.Output(context.Background(), "sh", "-c", "echo ok")
+	if err != nil {
+		t.Fatalf("Output() error = %v", err)
+	}
+	if string(output) != "ok\n" {
+		t.Errorf("Output() = %q, want %q", output, "ok\n")
+	}
+}
+
+func TestExec_Output_ExitStatus(t *testing.T) {
+	e := &Exec{}
+	output, err := e.Output(context.Background(), "sh", "-c", "echo partial; echo broken pipe >&2; exit 3")
+	if string(output) != "partial\n" {
+		t.Errorf("Output() = %q, want the output before the failure", output)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("Output() error = %v, want exit status 3", err)
+	}
+	var cmdErr *Error
+	if !errors.As(err, &cmdErr) || cmdErr.Stderr != "broken pipe" {
+		t.Errorf("Output() error = %v, want stderr %q", err, "broken pipe")
+	}
+	if IsTimeout(err) {
+		t.Error("IsTimeout() = true for a failed command")
+	}
+}
+
+func TestExec_Output_Timeout(t *testing.T) {
+	e := &Exec{Timeouts: map[string]time.Duration{"sh": 100 * time.Millisecond}}
+	start := time.Now()
+	// The background sleep keeps stdout open unless the whole process
+	// group is killed
+	_, err := e.Output(context.Background(), "sh", "-c", "sleep 10 & sleep 10")
+	if !IsTimeout(err) {
+		t.Fatalf("Output() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Output() returned after %v, want the process group killed", elapsed)
+	}
+}
+
+func TestExec_Output_Cancelled(t *testing.T) {
+	e := &Exec{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.Output(ctx, "sh", "-c", "sleep 10")
+	if err == nil || IsTimeout(err) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Output() error = %v, want it cancelled", err)
+	}
+}
+
+func TestExec_Timeout(t *testing.T) {
+	e := &Exec{Timeouts: map[string]time.Duration{"zpool": time.Minute}}
+	tests := []struct {
+		name     string
+		expected time.Duration
+	}{
+		{name: "zpool", expected: time.Minute},
+		{name: "/usr/sbin/smartctl", expected: Timeouts["smartctl"]},
+		{name: "ping", expected: Timeouts["ping"]},
+		{name: "hdparm-helper", expected: DefaultTimeout},
+	}
+	for _, tt := range tests {
+		if got := e.Timeout(tt.name); got != tt.expected {
+			t.Errorf("Timeout(%q) = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package command
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Result is the scripted outcome of one run of a tool
+type Result struct {
+	Output string
+	Stderr string
+	Err    error  // e.g. ErrTimeout, or &exec.ExitError{} for a non-zero exit status
+	Run    func() // called when the command runs, e.g. to change a fake sysfs
+}
+
+// Fake is a Runner that replays scripted results instead of running tools,
+// so that tests do not depend on the host. A command without a script fails
+// as if the tool were not installed. The zero value is ready to use.
+type Fake struct {
+	mu      sync.Mutex
+	scripts map[string][]Result // command line -> results still to come
+	calls   []string
+}
+
+// Script sets the results of a command line such as "zpool list -H". Each
+// run takes the next result, and the last one repeats.
+func (f *Fake) Script(cmdline string, results ...Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.scripts == nil {
+		f.scripts = make(map[string][]Result)
+	}
+	f.scripts[cmdline] = results
+}
+
+// Output returns the next scripted result of the command line
+func (f *Fake) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmdline := strings.Join(append([]string{name}, args...), " ")
+
+	f.mu.Lock()
+	f.calls = append(f.calls, cmdline)
+	results := f.scripts[cmdline]
+	var r Result
+	if len(results) == 0 {
+		r = Result{Err: exec.ErrNotFound}
+	} else {
+		r = results[0]
+		if len(results) > 1 {
+			f.scripts[cmdline] = results[1:]
+		}
+	}
+	f.mu.Unlock()
+
+	if r.Run != nil {
+		r.Run()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &Error{Name: name, Args: args, Err: err}
+	}
+	if r.Err != nil {
+		return []byte(r.Output), &Error{Name: name, Args: args, Stderr: r.Stderr, Err: r.Err}
+	}
+	return []byte(r.Output), nil
+}
+
+// Calls returns the command lines run so far, oldest first
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
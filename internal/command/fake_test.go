@@ -0,0 +1,53 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestFake_Output(t *testing.T) {
+	f := &Fake{}
+	f.Script("zpool list -H", Result{Output: "tank\t42\n"}, Result{Err: ErrTimeout})
+	ctx := context.Background()
+
+	output, err := f.Output(ctx, "zpool", "list", "-H")
+	if err != nil || string(output) != "tank\t42\n" {
+		t.Errorf("Output() = %q, %v, want the first result", output, err)
+	}
+	// The last result repeats
+	for i := 0; i < 2; i++ {
+		if _, err := f.Output(ctx, "zpool", "list", "-H"); !IsTimeout(err) {
+			t.Errorf("Output() error = %v, want a timeout", err)
+		}
+	}
+
+	if _, err := f.Output(ctx, "smartctl", "-a", "/dev/sda"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Output() of an unscripted command error = %v, want %v", err, exec.ErrNotFound)
+	}
+
+	expected := []string{"zpool list -H", "zpool list -H", "zpool list -H", "smartctl -a /dev/sda"}
+	if calls := f.Calls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Calls() = %v, want %v", calls, expected)
+	}
+}
+
+func TestFake_Output_ExitStatus(t *testing.T) {
+	f := &Fake{}
+	f.Script("smartctl -j -a /dev/sda", Result{Output: "{}", Stderr: "SMART failing", Err: &exec.ExitError{}})
+
+	output, err := f.Output(context.Background(), "smartctl", "-j", "-a", "/dev/sda")
+	if string(output) != "{}" {
+		t.Errorf("Output() = %q, want the output along with the exit status", output)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("Output() error = %v, want an *exec.ExitError", err)
+	}
+	var cmdErr *Error
+	if !errors.As(err, &cmdErr) || cmdErr.Stderr != "SMART failing" {
+		t.Errorf("Output() error = %v, want stderr %q", err, "SMART failing")
+	}
+}
@@ -15,6 +15,46 @@ func (r RGB) String() string {
 	return fmt.Sprintf("%d %d %d", r.R, r.G, r.B)
 }
 
+// parseSmartThresholds parses SMART attribute thresholds such as
+// "5:1 197:1", mapping attribute IDs to raw values
+func parseSmartThresholds(s string) map[int]uint64 {
+	thresholds := make(map[int]uint64)
+	for _, field := range strings.Fields(s) {
+		id, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		thresholds[i] = v
+	}
+	return thresholds
+}
+
+// parseCommandTimeouts parses tool timeouts such as "zpool:60 smartctl:30",
+// mapping tool names to seconds
+func parseCommandTimeouts(s string) map[string]int {
+	timeouts := make(map[string]int)
+	for _, field := range strings.Fields(s) {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		timeouts[name] = seconds
+	}
+	return timeouts
+}
+
 func parseRGB(s string) RGB {
 	parts := strings.Fields(s)
 	if len(parts) != 3 {
@@ -28,20 +68,85 @@ func parseRGB(s string) RGB {
 
 type DiskMonitorConfig struct {
 	Enable                bool
-	MappingMethod         string // "ata", "hctl", "serial"
+	MappingMethod         string // "ata", "hctl", "nvme", "serial", "wwn", "by-id", "by-path"
+	DiskMapping           []string // per-slot keys for serial, wwn, by-id and by-path mapping
 	CheckSmart            bool
 	CheckSmartInterval    int // seconds
+	CheckSmartFailedInterval int // seconds, re-check cadence for disks failing SMART
+	SmartWarnThresholds   map[int]uint64 // attribute ID -> raw value that shows ColorSmartWarn
+	SmartFailThresholds   map[int]uint64 // attribute ID -> raw value that shows ColorSmartFail
+	SmartDeltaThresholds  map[int]uint64 // attribute ID -> raw increase between polls that shows ColorSmartWarn
 	LedRefreshInterval    float64 // seconds
+	SeparateReadWrite     bool // show reads and writes with their own colors and blink lengths
+	ShowDiscardFlush      bool // also show discards and flushes when SeparateReadWrite is set
+	DiskReadBlinkInterval int // milliseconds
+	DiskWriteBlinkInterval int // milliseconds
+	ActivityScale         string // "none", "throughput" or "utilization"
+	ActivityScaleTarget   string // "brightness" or "blink"
+	ActivityScaleMaxThroughput int // MB/s shown as a saturated disk
+	CheckLatency          bool
+	LatencyFactor         float64 // times the median latency of the other disks that shows ColorDiskSlow
+	LatencyMin            int // milliseconds per request below which a disk is never slow
+	StallTimeout          int // seconds of I/O in flight without completions that show ColorDiskSlow
+	CheckIOErrors         bool
+	CheckIOErrorsInterval int // seconds
+	IOErrorFailThreshold  int // kernel I/O errors since startup that show ColorIOErrorFail instead of ColorIOErrorWarn
+	IOErrorQuietPeriod    int // seconds without new I/O errors after which they are forgotten, 0 keeps them until restart or ack
 	CheckZpool            bool
 	CheckZpoolInterval    int // seconds
 	DebugZpool            bool
+	CheckMdraid           bool
+	CheckMdraidInterval   int // seconds
+	CheckBtrfs            bool
+	CheckBtrfsInterval    int // seconds
+	CheckTemperature      bool
+	CheckTemperatureInterval int // seconds
+	TemperatureWarn       int // degrees Celsius where the healthy color starts shifting to ColorTemperatureHot
+	TemperatureCrit       int // degrees Celsius where the disk blinks ColorTemperatureCrit
+	TemperatureCritBlinkInterval int // milliseconds
+	CheckCapacity         bool
+	CheckCapacityInterval int // seconds
+	CapacityWarn          int // percent used where a healthy disk shows ColorCapacityWarn
+	CapacityCrit          int // percent used where a healthy disk shows ColorCapacityCrit
 	CheckDiskOnlineInterval int // seconds
+	HotplugRescanInterval int // seconds, used when uevents are unavailable
+	ControlSocket         string // Unix socket for identify commands, "" to disable
+	IdentifyTimeout       int // seconds a bay blinks ColorIdentify unless cleared
+	IdentifyBlinkInterval int // milliseconds
+	StateFile             string // JSON file keeping fault history across restarts, "" to disable
+	LatchFaults           []string // fault kinds that show until acknowledged: "smart", "zpool", "zpool-errors", "md", "btrfs", "btrfs-errors", "io", "offline"
+	LatchPattern          string // "fault" to keep showing a latched fault, "dim" to show it dimmed once the disk recovers
+	LatchedBrightness     int // brightness of the "dim" latch pattern
+	CommandTimeouts       map[string]int // tool name -> seconds, overriding the built-in timeouts
+	Root                  string // filesystem root holding sys, proc and dev, "" for the host
 	ColorDiskHealth       RGB
 	ColorDiskUnavail      RGB
 	ColorDiskStandby      RGB
+	ColorDiskRead         RGB
+	ColorDiskWrite        RGB
+	ColorDiskDiscard      RGB
+	ColorDiskFlush        RGB
+	ColorDiskSlow         RGB
 	ColorZpoolFail        RGB
+	ColorZpoolPoolDegraded RGB
+	ColorZpoolVdevDegraded RGB
+	ColorZpoolErrors      RGB
+	ZpoolErrorThreshold   int // READ, WRITE or CKSUM errors before a disk shows ColorZpoolErrors
+	ColorZpoolScrub       RGB
+	ColorZpoolResilver    RGB
+	ZpoolScanBlinkInterval int // milliseconds
 	ColorSmartFail        RGB
+	ColorSmartWarn        RGB
+	ColorIOErrorWarn      RGB
+	ColorIOErrorFail      RGB
+	ColorTemperatureHot   RGB
+	ColorTemperatureCrit  RGB
+	ColorCapacityWarn     RGB
+	ColorCapacityCrit     RGB
+	ColorIdentify         RGB
+	ColorCommandTimeout   RGB
 	BrightnessDiskLeds    int
+	CheckStandby          bool
 	StandbyMonPath        string
 	StandbyCheckInterval  int
 	BlinkMonPath          string
@@ -52,6 +157,7 @@ type NetworkMonitorConfig struct {
 	Interfaces                  []string
 	ColorNormal                 RGB
 	ColorGatewayUnreachable     RGB
+	ColorCommandTimeout         RGB // ip or ping timed out, so the gateway state is unknown
 	ColorLinkPurpleDefault      RGB
 	ColorLink100                *RGB
 	ColorLink1000               *RGB
@@ -71,6 +177,8 @@ type NetworkMonitorConfig struct {
 	BlinkTx                     int
 	BlinkRx                     int
 	BlinkInterval               int // milliseconds
+	CommandTimeouts             map[string]int // tool name -> seconds, overriding the built-in timeouts
+	Root                        string // filesystem root holding sys, "" for the host
 }
 
 type Config struct {
@@ -84,17 +192,79 @@ func (c *Config) setDefaults() {
 	c.DiskMonitor.MappingMethod = "ata"
 	c.DiskMonitor.CheckSmart = true
 	c.DiskMonitor.CheckSmartInterval = 360
+	c.DiskMonitor.CheckSmartFailedInterval = 3600
+	c.DiskMonitor.SmartWarnThresholds = parseSmartThresholds("5:1 187:1 197:1 198:1 199:100")
+	c.DiskMonitor.SmartFailThresholds = parseSmartThresholds("5:100 197:50 198:50")
+	c.DiskMonitor.SmartDeltaThresholds = parseSmartThresholds("5:1 187:1 197:1 198:1 199:10")
 	c.DiskMonitor.LedRefreshInterval = 0.1
+	c.DiskMonitor.SeparateReadWrite = false
+	c.DiskMonitor.ShowDiscardFlush = false
+	c.DiskMonitor.DiskReadBlinkInterval = 100
+	c.DiskMonitor.DiskWriteBlinkInterval = 200
+	c.DiskMonitor.ActivityScale = "none"
+	c.DiskMonitor.ActivityScaleTarget = "brightness"
+	c.DiskMonitor.ActivityScaleMaxThroughput = 250
+	c.DiskMonitor.CheckLatency = false
+	c.DiskMonitor.LatencyFactor = 5
+	c.DiskMonitor.LatencyMin = 100
+	c.DiskMonitor.StallTimeout = 10
+	c.DiskMonitor.CheckIOErrors = false
+	c.DiskMonitor.CheckIOErrorsInterval = 5
+	c.DiskMonitor.IOErrorFailThreshold = 50
+	c.DiskMonitor.IOErrorQuietPeriod = 3600
 	c.DiskMonitor.CheckZpool = false
 	c.DiskMonitor.CheckZpoolInterval = 5
 	c.DiskMonitor.DebugZpool = false
+	c.DiskMonitor.CheckMdraid = false
+	c.DiskMonitor.CheckMdraidInterval = 5
+	c.DiskMonitor.CheckBtrfs = false
+	c.DiskMonitor.CheckBtrfsInterval = 60
+	c.DiskMonitor.CheckTemperature = false
+	c.DiskMonitor.CheckTemperatureInterval = 60
+	c.DiskMonitor.TemperatureWarn = 45
+	c.DiskMonitor.TemperatureCrit = 55
+	c.DiskMonitor.TemperatureCritBlinkInterval = 250
+	c.DiskMonitor.CheckCapacity = false
+	c.DiskMonitor.CheckCapacityInterval = 300
+	c.DiskMonitor.CapacityWarn = 80
+	c.DiskMonitor.CapacityCrit = 90
 	c.DiskMonitor.CheckDiskOnlineInterval = 5
+	c.DiskMonitor.HotplugRescanInterval = 30
+	c.DiskMonitor.ControlSocket = "/run/ugreen-leds/control.sock"
+	c.DiskMonitor.IdentifyTimeout = 300
+	c.DiskMonitor.IdentifyBlinkInterval = 100
+	c.DiskMonitor.StateFile = "/var/lib/ugreen-leds/state.json"
+	c.DiskMonitor.LatchFaults = []string{}
+	c.DiskMonitor.LatchPattern = "fault"
+	c.DiskMonitor.LatchedBrightness = 64
 	c.DiskMonitor.ColorDiskHealth = RGB{255, 255, 255}
 	c.DiskMonitor.ColorDiskUnavail = RGB{255, 0, 0}
 	c.DiskMonitor.ColorDiskStandby = RGB{0, 0, 255}
+	c.DiskMonitor.ColorDiskRead = RGB{0, 255, 0}
+	c.DiskMonitor.ColorDiskWrite = RGB{0, 128, 255}
+	c.DiskMonitor.ColorDiskDiscard = RGB{128, 0, 255}
+	c.DiskMonitor.ColorDiskFlush = RGB{255, 255, 128}
+	c.DiskMonitor.ColorDiskSlow = RGB{255, 0, 128}
 	c.DiskMonitor.ColorZpoolFail = RGB{255, 0, 0}
+	c.DiskMonitor.ColorZpoolPoolDegraded = RGB{255, 255, 0}
+	c.DiskMonitor.ColorZpoolVdevDegraded = RGB{255, 128, 0}
+	c.DiskMonitor.ColorZpoolErrors = RGB{255, 64, 0}
+	c.DiskMonitor.ZpoolErrorThreshold = 1
+	c.DiskMonitor.ColorZpoolScrub = RGB{0, 255, 255}
+	c.DiskMonitor.ColorZpoolResilver = RGB{255, 0, 255}
+	c.DiskMonitor.ZpoolScanBlinkInterval = 1000
 	c.DiskMonitor.ColorSmartFail = RGB{255, 0, 0}
+	c.DiskMonitor.ColorSmartWarn = RGB{255, 192, 0}
+	c.DiskMonitor.ColorIOErrorWarn = RGB{255, 96, 0}
+	c.DiskMonitor.ColorIOErrorFail = RGB{255, 0, 0}
+	c.DiskMonitor.ColorTemperatureHot = RGB{255, 0, 0}
+	c.DiskMonitor.ColorTemperatureCrit = RGB{255, 0, 0}
+	c.DiskMonitor.ColorCapacityWarn = RGB{255, 255, 0}
+	c.DiskMonitor.ColorCapacityCrit = RGB{255, 128, 0}
+	c.DiskMonitor.ColorIdentify = RGB{0, 255, 0}
+	c.DiskMonitor.ColorCommandTimeout = RGB{128, 128, 128}
 	c.DiskMonitor.BrightnessDiskLeds = 255
+	c.DiskMonitor.CheckStandby = false
 	c.DiskMonitor.StandbyMonPath = "/usr/bin/ugreen-check-standby"
 	c.DiskMonitor.StandbyCheckInterval = 1
 	c.DiskMonitor.BlinkMonPath = "/usr/bin/ugreen-blink-disk"
@@ -103,6 +273,7 @@ func (c *Config) setDefaults() {
 	c.NetworkMonitor.Interfaces = []string{}
 	c.NetworkMonitor.ColorNormal = RGB{255, 255, 255}
 	c.NetworkMonitor.ColorGatewayUnreachable = RGB{255, 0, 0}
+	c.NetworkMonitor.ColorCommandTimeout = RGB{128, 128, 128}
 	c.NetworkMonitor.ColorLinkPurpleDefault = RGB{128, 0, 128}
 	c.NetworkMonitor.BrightnessLed = 255
 	c.NetworkMonitor.CheckInterval = 60
@@ -202,13 +373,89 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.DiskMonitor.MappingMethod == "" {
 		cfg.DiskMonitor.MappingMethod = "ata"
 	}
+	if v := getValue("DISK_MAPPING"); v != "" {
+		cfg.DiskMonitor.DiskMapping = strings.Fields(v)
+	}
 	cfg.DiskMonitor.CheckSmart = getBool("CHECK_SMART", cfg.DiskMonitor.CheckSmart)
 	cfg.DiskMonitor.CheckSmartInterval = getInt("CHECK_SMART_INTERVAL", cfg.DiskMonitor.CheckSmartInterval)
+	cfg.DiskMonitor.CheckSmartFailedInterval = getInt("CHECK_SMART_FAILED_INTERVAL", cfg.DiskMonitor.CheckSmartFailedInterval)
+	// An empty threshold list disables those checks
+	if v, ok := configMap["SMART_WARN_THRESHOLDS"]; ok {
+		cfg.DiskMonitor.SmartWarnThresholds = parseSmartThresholds(v)
+	}
+	if v, ok := configMap["SMART_FAIL_THRESHOLDS"]; ok {
+		cfg.DiskMonitor.SmartFailThresholds = parseSmartThresholds(v)
+	}
+	if v, ok := configMap["SMART_DELTA_THRESHOLDS"]; ok {
+		cfg.DiskMonitor.SmartDeltaThresholds = parseSmartThresholds(v)
+	}
 	cfg.DiskMonitor.LedRefreshInterval = getFloat("LED_REFRESH_INTERVAL", cfg.DiskMonitor.LedRefreshInterval)
+	cfg.DiskMonitor.SeparateReadWrite = getBool("SEPARATE_READ_WRITE", cfg.DiskMonitor.SeparateReadWrite)
+	cfg.DiskMonitor.ShowDiscardFlush = getBool("SHOW_DISCARD_FLUSH", cfg.DiskMonitor.ShowDiscardFlush)
+	cfg.DiskMonitor.DiskReadBlinkInterval = getInt("DISK_READ_BLINK_INTERVAL", cfg.DiskMonitor.DiskReadBlinkInterval)
+	cfg.DiskMonitor.DiskWriteBlinkInterval = getInt("DISK_WRITE_BLINK_INTERVAL", cfg.DiskMonitor.DiskWriteBlinkInterval)
+	if v := getValue("ACTIVITY_SCALE"); v != "" {
+		cfg.DiskMonitor.ActivityScale = v
+	}
+	if v := getValue("ACTIVITY_SCALE_TARGET"); v != "" {
+		cfg.DiskMonitor.ActivityScaleTarget = v
+	}
+	cfg.DiskMonitor.ActivityScaleMaxThroughput = getInt("ACTIVITY_SCALE_MAX_THROUGHPUT", cfg.DiskMonitor.ActivityScaleMaxThroughput)
+	cfg.DiskMonitor.CheckLatency = getBool("CHECK_LATENCY", cfg.DiskMonitor.CheckLatency)
+	cfg.DiskMonitor.LatencyFactor = getFloat("LATENCY_FACTOR", cfg.DiskMonitor.LatencyFactor)
+	cfg.DiskMonitor.LatencyMin = getInt("LATENCY_MIN", cfg.DiskMonitor.LatencyMin)
+	cfg.DiskMonitor.StallTimeout = getInt("STALL_TIMEOUT", cfg.DiskMonitor.StallTimeout)
+	cfg.DiskMonitor.CheckIOErrors = getBool("CHECK_IO_ERRORS", cfg.DiskMonitor.CheckIOErrors)
+	cfg.DiskMonitor.CheckIOErrorsInterval = getInt("CHECK_IO_ERRORS_INTERVAL", cfg.DiskMonitor.CheckIOErrorsInterval)
+	cfg.DiskMonitor.IOErrorFailThreshold = getInt("IO_ERROR_FAIL_THRESHOLD", cfg.DiskMonitor.IOErrorFailThreshold)
+	cfg.DiskMonitor.IOErrorQuietPeriod = getInt("IO_ERROR_QUIET_PERIOD", cfg.DiskMonitor.IOErrorQuietPeriod)
 	cfg.DiskMonitor.CheckZpool = getBool("CHECK_ZPOOL", cfg.DiskMonitor.CheckZpool)
 	cfg.DiskMonitor.CheckZpoolInterval = getInt("CHECK_ZPOOL_INTERVAL", cfg.DiskMonitor.CheckZpoolInterval)
 	cfg.DiskMonitor.DebugZpool = getBool("DEBUG_ZPOOL", cfg.DiskMonitor.DebugZpool)
+	cfg.DiskMonitor.CheckMdraid = getBool("CHECK_MDRAID", cfg.DiskMonitor.CheckMdraid)
+	cfg.DiskMonitor.CheckMdraidInterval = getInt("CHECK_MDRAID_INTERVAL", cfg.DiskMonitor.CheckMdraidInterval)
+	cfg.DiskMonitor.CheckBtrfs = getBool("CHECK_BTRFS", cfg.DiskMonitor.CheckBtrfs)
+	cfg.DiskMonitor.CheckBtrfsInterval = getInt("CHECK_BTRFS_INTERVAL", cfg.DiskMonitor.CheckBtrfsInterval)
+	cfg.DiskMonitor.CheckTemperature = getBool("CHECK_TEMPERATURE", cfg.DiskMonitor.CheckTemperature)
+	cfg.DiskMonitor.CheckTemperatureInterval = getInt("CHECK_TEMPERATURE_INTERVAL", cfg.DiskMonitor.CheckTemperatureInterval)
+	cfg.DiskMonitor.TemperatureWarn = getInt("TEMPERATURE_WARN", cfg.DiskMonitor.TemperatureWarn)
+	cfg.DiskMonitor.TemperatureCrit = getInt("TEMPERATURE_CRIT", cfg.DiskMonitor.TemperatureCrit)
+	cfg.DiskMonitor.TemperatureCritBlinkInterval = getInt("TEMPERATURE_CRIT_BLINK_INTERVAL", cfg.DiskMonitor.TemperatureCritBlinkInterval)
+	cfg.DiskMonitor.CheckCapacity = getBool("CHECK_CAPACITY", cfg.DiskMonitor.CheckCapacity)
+	cfg.DiskMonitor.CheckCapacityInterval = getInt("CHECK_CAPACITY_INTERVAL", cfg.DiskMonitor.CheckCapacityInterval)
+	cfg.DiskMonitor.CapacityWarn = getInt("CAPACITY_WARN", cfg.DiskMonitor.CapacityWarn)
+	cfg.DiskMonitor.CapacityCrit = getInt("CAPACITY_CRIT", cfg.DiskMonitor.CapacityCrit)
 	cfg.DiskMonitor.CheckDiskOnlineInterval = getInt("CHECK_DISK_ONLINE_INTERVAL", cfg.DiskMonitor.CheckDiskOnlineInterval)
+	cfg.DiskMonitor.HotplugRescanInterval = getInt("HOTPLUG_RESCAN_INTERVAL", cfg.DiskMonitor.HotplugRescanInterval)
+	// An empty socket path disables the control socket
+	if v, ok := configMap["CONTROL_SOCKET"]; ok {
+		cfg.DiskMonitor.ControlSocket = v
+	}
+	cfg.DiskMonitor.IdentifyTimeout = getInt("IDENTIFY_TIMEOUT", cfg.DiskMonitor.IdentifyTimeout)
+	cfg.DiskMonitor.IdentifyBlinkInterval = getInt("IDENTIFY_BLINK_INTERVAL", cfg.DiskMonitor.IdentifyBlinkInterval)
+	// An empty state file disables the fault history
+	if v, ok := configMap["STATE_FILE"]; ok {
+		cfg.DiskMonitor.StateFile = v
+	}
+	// An empty list latches no faults
+	if v, ok := configMap["LATCH_FAULTS"]; ok {
+		cfg.DiskMonitor.LatchFaults = strings.Fields(v)
+	}
+	if v := getValue("LATCH_PATTERN"); v != "" {
+		cfg.DiskMonitor.LatchPattern = v
+	}
+	cfg.DiskMonitor.LatchedBrightness = getInt("LATCHED_BRIGHTNESS", cfg.DiskMonitor.LatchedBrightness)
+	// Both monitors run external tools with the same timeouts
+	if v := getValue("COMMAND_TIMEOUTS"); v != "" {
+		cfg.DiskMonitor.CommandTimeouts = parseCommandTimeouts(v)
+		cfg.NetworkMonitor.CommandTimeouts = parseCommandTimeouts(v)
+	}
+	// A root other than / reads the host through a copy or bind mount of
+	// its /sys, /proc and /dev, e.g. in a container
+	if v := getValue("FS_ROOT"); v != "" && v != "/" {
+		cfg.DiskMonitor.Root = v
+		cfg.NetworkMonitor.Root = v
+	}
 	if v := getValue("COLOR_DISK_HEALTH"); v != "" {
 		cfg.DiskMonitor.ColorDiskHealth = parseRGB(v)
 	}
@@ -218,13 +465,73 @@ func LoadConfig(path string) (*Config, error) {
 	if v := getValue("COLOR_DISK_STANDBY"); v != "" {
 		cfg.DiskMonitor.ColorDiskStandby = parseRGB(v)
 	}
+	if v := getValue("COLOR_DISK_READ"); v != "" {
+		cfg.DiskMonitor.ColorDiskRead = parseRGB(v)
+	}
+	if v := getValue("COLOR_DISK_WRITE"); v != "" {
+		cfg.DiskMonitor.ColorDiskWrite = parseRGB(v)
+	}
+	if v := getValue("COLOR_DISK_DISCARD"); v != "" {
+		cfg.DiskMonitor.ColorDiskDiscard = parseRGB(v)
+	}
+	if v := getValue("COLOR_DISK_FLUSH"); v != "" {
+		cfg.DiskMonitor.ColorDiskFlush = parseRGB(v)
+	}
+	if v := getValue("COLOR_DISK_SLOW"); v != "" {
+		cfg.DiskMonitor.ColorDiskSlow = parseRGB(v)
+	}
 	if v := getValue("COLOR_ZPOOL_FAIL"); v != "" {
 		cfg.DiskMonitor.ColorZpoolFail = parseRGB(v)
 	}
+	if v := getValue("COLOR_ZPOOL_POOL_DEGRADED"); v != "" {
+		cfg.DiskMonitor.ColorZpoolPoolDegraded = parseRGB(v)
+	}
+	if v := getValue("COLOR_ZPOOL_VDEV_DEGRADED"); v != "" {
+		cfg.DiskMonitor.ColorZpoolVdevDegraded = parseRGB(v)
+	}
+	if v := getValue("COLOR_ZPOOL_ERRORS"); v != "" {
+		cfg.DiskMonitor.ColorZpoolErrors = parseRGB(v)
+	}
+	cfg.DiskMonitor.ZpoolErrorThreshold = getInt("ZPOOL_ERROR_THRESHOLD", cfg.DiskMonitor.ZpoolErrorThreshold)
+	if v := getValue("COLOR_ZPOOL_SCRUB"); v != "" {
+		cfg.DiskMonitor.ColorZpoolScrub = parseRGB(v)
+	}
+	if v := getValue("COLOR_ZPOOL_RESILVER"); v != "" {
+		cfg.DiskMonitor.ColorZpoolResilver = parseRGB(v)
+	}
+	cfg.DiskMonitor.ZpoolScanBlinkInterval = getInt("ZPOOL_SCAN_BLINK_INTERVAL", cfg.DiskMonitor.ZpoolScanBlinkInterval)
 	if v := getValue("COLOR_SMART_FAIL"); v != "" {
 		cfg.DiskMonitor.ColorSmartFail = parseRGB(v)
 	}
+	if v := getValue("COLOR_SMART_WARN"); v != "" {
+		cfg.DiskMonitor.ColorSmartWarn = parseRGB(v)
+	}
+	if v := getValue("COLOR_IO_ERROR_WARN"); v != "" {
+		cfg.DiskMonitor.ColorIOErrorWarn = parseRGB(v)
+	}
+	if v := getValue("COLOR_IO_ERROR_FAIL"); v != "" {
+		cfg.DiskMonitor.ColorIOErrorFail = parseRGB(v)
+	}
+	if v := getValue("COLOR_TEMPERATURE_HOT"); v != "" {
+		cfg.DiskMonitor.ColorTemperatureHot = parseRGB(v)
+	}
+	if v := getValue("COLOR_TEMPERATURE_CRIT"); v != "" {
+		cfg.DiskMonitor.ColorTemperatureCrit = parseRGB(v)
+	}
+	if v := getValue("COLOR_CAPACITY_WARN"); v != "" {
+		cfg.DiskMonitor.ColorCapacityWarn = parseRGB(v)
+	}
+	if v := getValue("COLOR_CAPACITY_CRIT"); v != "" {
+		cfg.DiskMonitor.ColorCapacityCrit = parseRGB(v)
+	}
+	if v := getValue("COLOR_IDENTIFY"); v != "" {
+		cfg.DiskMonitor.ColorIdentify = parseRGB(v)
+	}
+	if v := getValue("COLOR_COMMAND_TIMEOUT"); v != "" {
+		cfg.DiskMonitor.ColorCommandTimeout = parseRGB(v)
+	}
 	cfg.DiskMonitor.BrightnessDiskLeds = getInt("BRIGHTNESS_DISK_LEDS", cfg.DiskMonitor.BrightnessDiskLeds)
+	cfg.DiskMonitor.CheckStandby = getBool("CHECK_STANDBY", cfg.DiskMonitor.CheckStandby)
 	cfg.DiskMonitor.StandbyMonPath = getValue("STANDBY_MON_PATH")
 	if cfg.DiskMonitor.StandbyMonPath == "" {
 		cfg.DiskMonitor.StandbyMonPath = "/usr/bin/ugreen-check-standby"
@@ -246,6 +553,9 @@ func LoadConfig(path string) (*Config, error) {
 	if v := getValue("COLOR_NETDEV_GATEWAY_UNREACHABLE"); v != "" {
 		cfg.NetworkMonitor.ColorGatewayUnreachable = parseRGB(v)
 	}
+	if v := getValue("COLOR_NETDEV_COMMAND_TIMEOUT"); v != "" {
+		cfg.NetworkMonitor.ColorCommandTimeout = parseRGB(v)
+	}
 	if v := getValue("COLOR_NETDEV_LINK_PURPLE_DEFAULT"); v != "" {
 		cfg.NetworkMonitor.ColorLinkPurpleDefault = parseRGB(v)
 	}
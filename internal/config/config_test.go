@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -317,3 +318,188 @@ func TestLoadConfig_FloatValues(t *testing.T) {
 	}
 }
 
+
+func TestLoadConfig_DiskMapping(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.conf")
+
+	configContent := `MAPPING_METHOD=by-path
+DISK_MAPPING="pci-0000:00:17.0-ata-1 pci-0000:00:17.0-ata-2"
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want nil", err)
+	}
+
+	if cfg.DiskMonitor.MappingMethod != "by-path" {
+		t.Errorf("MappingMethod = %q, want %q", cfg.DiskMonitor.MappingMethod, "by-path")
+	}
+	if len(cfg.DiskMonitor.DiskMapping) != 2 || cfg.DiskMonitor.DiskMapping[1] != "pci-0000:00:17.0-ata-2" {
+		t.Errorf("DiskMapping = %v, want [pci-0000:00:17.0-ata-1 pci-0000:00:17.0-ata-2]", cfg.DiskMonitor.DiskMapping)
+	}
+}
+
+func TestParseSmartThresholds(t *testing.T) {
+	result := parseSmartThresholds("5:1 197:50 bogus 198:x 199:100")
+	expected := map[int]uint64{5: 1, 197: 50, 199: 100}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseSmartThresholds() = %v, want %v", result, expected)
+	}
+}
+
+func TestLoadConfig_SmartThresholds(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.conf")
+
+	configContent := `SMART_WARN_THRESHOLDS="5:8 197:2"
+SMART_DELTA_THRESHOLDS=""
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want nil", err)
+	}
+
+	if expected := map[int]uint64{5: 8, 197: 2}; !reflect.DeepEqual(cfg.DiskMonitor.SmartWarnThresholds, expected) {
+		t.Errorf("SmartWarnThresholds = %v, want %v", cfg.DiskMonitor.SmartWarnThresholds, expected)
+	}
+	// Unset keys keep their defaults, empty ones disable the check
+	if cfg.DiskMonitor.SmartFailThresholds[5] != 100 {
+		t.Errorf("SmartFailThresholds[5] = %d, want %d", cfg.DiskMonitor.SmartFailThresholds[5], 100)
+	}
+	if len(cfg.DiskMonitor.SmartDeltaThresholds) != 0 {
+		t.Errorf("SmartDeltaThresholds = %v, want none", cfg.DiskMonitor.SmartDeltaThresholds)
+	}
+}
+
+func TestLoadConfig_ControlSocket(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "default", content: "", expected: "/run/ugreen-leds/control.sock"},
+		{name: "custom", content: "CONTROL_SOCKET=/run/leds.sock\n", expected: "/run/leds.sock"},
+		{name: "disabled", content: "CONTROL_SOCKET=\"\"\n", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(tmpDir, tt.name+".conf")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			cfg, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v, want nil", err)
+			}
+			if cfg.DiskMonitor.ControlSocket != tt.expected {
+				t.Errorf("ControlSocket = %q, want %q", cfg.DiskMonitor.ControlSocket, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_LatchFaults(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{name: "default", content: "", expected: []string{}},
+		{name: "custom", content: "LATCH_FAULTS=\"smart io offline\"\n", expected: []string{"smart", "io", "offline"}},
+		{name: "disabled", content: "LATCH_FAULTS=\"\"\n", expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(tmpDir, tt.name+".conf")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			cfg, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v, want nil", err)
+			}
+			if len(cfg.DiskMonitor.LatchFaults) != len(tt.expected) {
+				t.Fatalf("LatchFaults = %v, want %v", cfg.DiskMonitor.LatchFaults, tt.expected)
+			}
+			for i := range tt.expected {
+				if cfg.DiskMonitor.LatchFaults[i] != tt.expected[i] {
+					t.Errorf("LatchFaults = %v, want %v", cfg.DiskMonitor.LatchFaults, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCommandTimeouts(t *testing.T) {
+	expected := map[string]int{"zpool": 60, "smartctl": 45}
+	result := parseCommandTimeouts("zpool:60 smartctl:45 ping:0 ip:x dmidecode")
+	if len(result) != len(expected) {
+		t.Fatalf("parseCommandTimeouts() = %v, want %v", result, expected)
+	}
+	for name, seconds := range expected {
+		if result[name] != seconds {
+			t.Errorf("parseCommandTimeouts()[%q] = %d, want %d", name, result[name], seconds)
+		}
+	}
+}
+
+func TestLoadConfig_CommandTimeouts(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.conf")
+	if err := os.WriteFile(configPath, []byte("COMMAND_TIMEOUTS=\"zpool:60\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want nil", err)
+	}
+	if cfg.DiskMonitor.CommandTimeouts["zpool"] != 60 || cfg.NetworkMonitor.CommandTimeouts["zpool"] != 60 {
+		t.Errorf("CommandTimeouts = %v and %v, want zpool:60 for both monitors", cfg.DiskMonitor.CommandTimeouts, cfg.NetworkMonitor.CommandTimeouts)
+	}
+}
+
+func TestLoadConfig_FSRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "default", content: "", expected: ""},
+		{name: "host", content: "FS_ROOT=/\n", expected: ""},
+		{name: "custom", content: "FS_ROOT=/host\n", expected: "/host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(tmpDir, tt.name+".conf")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			cfg, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v, want nil", err)
+			}
+			if cfg.DiskMonitor.Root != tt.expected || cfg.NetworkMonitor.Root != tt.expected {
+				t.Errorf("Root = %q and %q, want %q", cfg.DiskMonitor.Root, cfg.NetworkMonitor.Root, tt.expected)
+			}
+		})
+	}
+}
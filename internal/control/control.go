@@ -0,0 +1,113 @@
+// Package control passes commands such as "identify sda" from the command
+// line to the running service over a Unix socket. A request is one line of
+// space-separated words; the reply is text, or a line starting with
+// "error: " if the command failed.
+package control
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// timeout bounds how long a client may take to send a command and read the
+// reply
+const timeout = 5 * time.Second
+
+// Handler runs a command and returns its reply
+type Handler func(args []string) (string, error)
+
+// Serve listens on the socket at path and runs each command with handle
+// until ctx is cancelled. A stale socket left by a previous run is replaced.
+func Serve(ctx context.Context, path string, handle Handler) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// Commands change what the LEDs show, so only root may send them. The
+	// socket is created with the umask, so it must not be connectable
+	// before a chmod could tighten it.
+	oldMask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, handle)
+	}
+}
+
+func serveConn(conn net.Conn, handle Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		fmt.Fprintln(conn, "error: empty command")
+		return
+	}
+
+	reply, err := handle(args)
+	if err != nil {
+		fmt.Fprintf(conn, "error: %v\n", err)
+		return
+	}
+	if reply != "" && !strings.HasSuffix(reply, "\n") {
+		reply += "\n"
+	}
+	io.WriteString(conn, reply)
+}
+
+// Send sends a command to the service listening on the socket at path and
+// returns its reply
+func Send(path string, args ...string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return "", fmt.Errorf("service not reachable: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := fmt.Fprintln(conn, strings.Join(args, " ")); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+
+	reply := string(data)
+	if msg, ok := strings.CutPrefix(reply, "error: "); ok {
+		return "", errors.New(strings.TrimSpace(msg))
+	}
+	return reply, nil
+}
@@ -0,0 +1,85 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves handle on a socket in a temporary directory until the
+// test ends
+func startServer(t *testing.T, handle Handler) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run", "control.sock")
+	// A socket left behind by a crashed service
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("Failed to write stale socket: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, path, handle)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("socket still exists after Serve() returned: %v", err)
+		}
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("socket was not created")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSend(t *testing.T) {
+	path := startServer(t, func(args []string) (string, error) {
+		if args[0] == "fail" {
+			return "", errors.New("no such disk")
+		}
+		return strings.Join(args, ","), nil
+	})
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v, want %v", info.Mode().Perm(), err, os.FileMode(0600))
+	}
+
+	reply, err := Send(path, "identify", "sda", "5m")
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if reply != "identify,sda,5m\n" {
+		t.Errorf("Send() = %q, want %q", reply, "identify,sda,5m\n")
+	}
+
+	if _, err := Send(path, "fail"); err == nil || err.Error() != "no such disk" {
+		t.Errorf("Send() error = %v, want %q", err, "no such disk")
+	}
+	if _, err := Send(path); err == nil || err.Error() != "empty command" {
+		t.Errorf("Send() of no command error = %v, want %q", err, "empty command")
+	}
+}
+
+func TestSend_NotRunning(t *testing.T) {
+	if _, err := Send(filepath.Join(t.TempDir(), "control.sock"), "identify", "sda"); err == nil {
+		t.Error("Send() without a service should fail")
+	}
+}
@@ -0,0 +1,128 @@
+package diskmon
+
+import (
+	"math"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// ioActivity is the kind of I/O a disk did since the last check, in order
+// of precedence when it did several
+type ioActivity int
+
+const (
+	ioIdle ioActivity = iota
+	ioFlush
+	ioDiscard
+	ioRead
+	ioWrite
+)
+
+// ioCounters holds the request counters of a block device from
+// /proc/diskstats, see Documentation/admin-guide/iostats.rst
+type ioCounters struct {
+	reads          uint64
+	sectorsRead    uint64
+	readTicks      uint64 // milliseconds spent reading
+	writes         uint64
+	sectorsWritten uint64
+	writeTicks     uint64 // milliseconds spent writing
+	inFlight       uint64 // requests issued but not completed
+	ioTicks        uint64 // milliseconds spent doing I/O
+	discards       uint64 // since Linux 4.18
+	flushes        uint64 // since Linux 5.5
+}
+
+// activity returns the kind of I/O done between prev and c. Discards and
+// flushes only count if showDiscardFlush is set.
+func (c ioCounters) activity(prev ioCounters, showDiscardFlush bool) ioActivity {
+	switch {
+	case c.writes != prev.writes:
+		return ioWrite
+	case c.reads != prev.reads:
+		return ioRead
+	case showDiscardFlush && c.discards != prev.discards:
+		return ioDiscard
+	case showDiscardFlush && c.flushes != prev.flushes:
+		return ioFlush
+	}
+	return ioIdle
+}
+
+// activityColor returns the color a healthy disk shows while doing I/O of
+// the given kind
+func (m *Monitor) activityColor(a ioActivity, idle config.RGB) config.RGB {
+	switch a {
+	case ioRead:
+		return m.cfg.ColorDiskRead
+	case ioWrite:
+		return m.cfg.ColorDiskWrite
+	case ioDiscard:
+		return m.cfg.ColorDiskDiscard
+	case ioFlush:
+		return m.cfg.ColorDiskFlush
+	}
+	return idle
+}
+
+// activityShot returns the oneshot blink length in milliseconds for I/O of
+// the given kind
+func (m *Monitor) activityShot(a ioActivity) int {
+	shot := 100
+	switch a {
+	case ioRead, ioFlush:
+		shot = m.cfg.DiskReadBlinkInterval
+	case ioWrite, ioDiscard:
+		shot = m.cfg.DiskWriteBlinkInterval
+	}
+	if shot <= 0 {
+		shot = 100 // Default to 100 milliseconds if invalid
+	}
+	return shot
+}
+
+// counterDelta returns how much a counter rose, or 0 if it was reset
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// ioLoad returns how busy a disk was between two samples taken elapsed
+// seconds apart, from 0 for idle to 1 for saturated, as configured by
+// ActivityScale
+func (m *Monitor) ioLoad(prev, cur ioCounters, elapsed float64) float64 {
+	var load float64
+	switch m.cfg.ActivityScale {
+	case "throughput":
+		maxThroughput := m.cfg.ActivityScaleMaxThroughput
+		if maxThroughput <= 0 {
+			maxThroughput = 250 // Default to 250 MB/s if invalid
+		}
+		sectors := counterDelta(prev.sectorsRead, cur.sectorsRead) + counterDelta(prev.sectorsWritten, cur.sectorsWritten)
+		// Sector counts are always in 512 byte units
+		load = float64(sectors) * 512 / elapsed / (float64(maxThroughput) * 1e6)
+	case "utilization":
+		load = float64(counterDelta(prev.ioTicks, cur.ioTicks)) / (elapsed * 1000)
+	default:
+		return 0
+	}
+	return math.Min(load, 1)
+}
+
+// scaleLook applies the I/O load of a disk to its LED look, by brightness
+// or by blink length as configured by ActivityScaleTarget
+func (m *Monitor) scaleLook(l *ledLook, load float64) {
+	switch m.cfg.ActivityScale {
+	case "throughput", "utilization":
+	default:
+		return
+	}
+	if m.cfg.ActivityScaleTarget == "blink" {
+		// Busier disks blink faster, down to a quarter of the blink length
+		l.shot = max(1, int(float64(l.shot)*(1-0.75*load)))
+		return
+	}
+	l.brightness = scanBrightness(l.brightness, load)
+}
@@ -0,0 +1,166 @@
+package diskmon
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/clock"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func TestIOCounters_Activity(t *testing.T) {
+	prev := ioCounters{reads: 10, writes: 10, discards: 10, flushes: 10}
+	tests := []struct {
+		name             string
+		counters         ioCounters
+		showDiscardFlush bool
+		expected         ioActivity
+	}{
+		{name: "idle", counters: prev, expected: ioIdle},
+		{name: "read", counters: ioCounters{reads: 11, writes: 10, discards: 10, flushes: 10}, expected: ioRead},
+		{name: "write over read", counters: ioCounters{reads: 11, writes: 11, discards: 10, flushes: 11}, expected: ioWrite},
+		{name: "discard hidden", counters: ioCounters{reads: 10, writes: 10, discards: 11, flushes: 10}, expected: ioIdle},
+		{name: "discard", counters: ioCounters{reads: 10, writes: 10, discards: 11, flushes: 11}, showDiscardFlush: true, expected: ioDiscard},
+		{name: "flush", counters: ioCounters{reads: 10, writes: 10, discards: 10, flushes: 11}, showDiscardFlush: true, expected: ioFlush},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.counters.activity(prev, tt.showDiscardFlush); result != tt.expected {
+				t.Errorf("activity() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_CheckIO_SeparateReadWrite(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks()[:1])
+	cfg := hotplugConfig()
+	cfg.SeparateReadWrite = true
+	cfg.ColorDiskRead = config.RGB{R: 0, G: 255, B: 0}
+	cfg.ColorDiskWrite = config.RGB{R: 0, G: 0, B: 255}
+	cfg.DiskReadBlinkInterval = 50
+	cfg.DiskWriteBlinkInterval = 300
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	writeDiskstats(t, root, "sda", "0 0 0 0 0 0 0 0 0 0 0")
+	m.checkIO()
+
+	steps := []struct {
+		name  string
+		stat  string
+		color string
+		delay string
+	}{
+		{name: "read", stat: "5 0 40 1 0 0 0 0 0 1 1", color: "0 255 0", delay: "50"},
+		{name: "write", stat: "5 0 40 1 3 0 24 2 0 2 3", color: "0 0 255", delay: "300"},
+		// An idle disk goes back to the healthy color but keeps its blink length
+		{name: "idle", stat: "5 0 40 1 3 0 24 2 0 2 3", color: "255 255 255", delay: "300"},
+	}
+	for _, step := range steps {
+		writeDiskstats(t, root, "sda", step.stat)
+		m.checkIO()
+		if color := readLED(t, root, "disk1", "color"); color != step.color {
+			t.Errorf("%s: disk1 color = %q, want %q", step.name, color, step.color)
+		}
+		if delay := readLED(t, root, "disk1", "delay_on"); delay != step.delay {
+			t.Errorf("%s: disk1 delay_on = %q, want %q", step.name, delay, step.delay)
+		}
+	}
+}
+
+func TestMonitor_IOLoad(t *testing.T) {
+	prev := ioCounters{sectorsRead: 1000, sectorsWritten: 1000, ioTicks: 500}
+	// 10 MB in 0.1 seconds and 50 ms of I/O
+	cur := ioCounters{sectorsRead: 1000 + 9766, sectorsWritten: 1000 + 9766, ioTicks: 550}
+
+	tests := []struct {
+		name     string
+		scale    string
+		prev     ioCounters
+		expected float64
+	}{
+		{name: "none", scale: "none", prev: prev, expected: 0},
+		{name: "throughput", scale: "throughput", prev: prev, expected: 0.4},
+		{name: "utilization", scale: "utilization", prev: prev, expected: 0.5},
+		{name: "saturated", scale: "utilization", prev: ioCounters{}, expected: 1},
+		{name: "counters reset", scale: "throughput", prev: ioCounters{sectorsRead: 1 << 40, sectorsWritten: 1 << 40}, expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Monitor{cfg: &config.DiskMonitorConfig{ActivityScale: tt.scale, ActivityScaleMaxThroughput: 250}}
+			if result := m.ioLoad(tt.prev, cur, 0.1); math.Abs(result-tt.expected) > 0.01 {
+				t.Errorf("ioLoad() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_ScaleLook(t *testing.T) {
+	tests := []struct {
+		name     string
+		scale    string
+		target   string
+		load     float64
+		expected ledLook
+	}{
+		{name: "disabled", scale: "none", target: "brightness", load: 1, expected: ledLook{brightness: 200, shot: 100}},
+		{name: "idle brightness", scale: "throughput", target: "brightness", load: 0, expected: ledLook{brightness: 50, shot: 100}},
+		{name: "saturated brightness", scale: "throughput", target: "brightness", load: 1, expected: ledLook{brightness: 200, shot: 100}},
+		{name: "idle blink", scale: "utilization", target: "blink", load: 0, expected: ledLook{brightness: 200, shot: 100}},
+		{name: "saturated blink", scale: "utilization", target: "blink", load: 1, expected: ledLook{brightness: 200, shot: 25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Monitor{cfg: &config.DiskMonitorConfig{ActivityScale: tt.scale, ActivityScaleTarget: tt.target}}
+			l := ledLook{brightness: 200, shot: 100}
+			m.scaleLook(&l, tt.load)
+			if l != tt.expected {
+				t.Errorf("scaleLook() = %+v, want %+v", l, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_CheckIO_ActivityScale(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks()[:1])
+	cfg := hotplugConfig()
+	cfg.LedRefreshInterval = 0.1
+	cfg.ActivityScale = "utilization"
+	cfg.ActivityScaleTarget = "brightness"
+	cfg.BrightnessDiskLeds = 200
+	m := newTestMonitor(t, root, cfg)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	m.clk = clk
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	writeDiskstats(t, root, "sda", "0 0 0 0 0 0 0 0 0 0 0")
+	m.checkIO()
+
+	steps := []struct {
+		name       string
+		elapsed    time.Duration
+		stat       string
+		brightness string
+	}{
+		// Busy for all of the 100 ms tick
+		{name: "saturated", elapsed: 100 * time.Millisecond, stat: "5 0 40 1 0 0 0 0 0 100 1", brightness: "200"},
+		{name: "half busy", elapsed: 100 * time.Millisecond, stat: "9 0 80 2 0 0 0 0 0 150 2", brightness: "125"},
+		{name: "idle", elapsed: 100 * time.Millisecond, stat: "9 0 80 2 0 0 0 0 0 150 2", brightness: "50"},
+		// A late tick spreads the busy time over the time that passed
+		{name: "late tick", elapsed: 200 * time.Millisecond, stat: "13 0 120 3 0 0 0 0 0 250 3", brightness: "125"},
+	}
+	for _, step := range steps {
+		clk.Advance(step.elapsed)
+		writeDiskstats(t, root, "sda", step.stat)
+		m.checkIO()
+		if brightness := readLED(t, root, "disk1", "brightness"); brightness != step.brightness {
+			t.Errorf("%s: disk1 brightness = %q, want %q", step.name, brightness, step.brightness)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package diskmon
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/blockdev"
+)
+
+// Offsets into the btrfs superblock, which starts 64KiB into each device
+const (
+	btrfsSuperOffset = 0x10000
+	btrfsMagicOffset = 0x40 // "_BHRfS_M"
+	btrfsDevidOffset = 0xc9 // devid in the dev_item describing the device itself
+	btrfsMagic       = "_BHRfS_M"
+)
+
+// btrfsFS is a btrfs filesystem and its devices
+type btrfsFS struct {
+	uuid    string
+	label   string
+	total   int // devices the filesystem should have
+	devices []btrfsDevice
+}
+
+// btrfsDevice is a device of a btrfs filesystem
+type btrfsDevice struct {
+	devid   string
+	name    string // kernel name, e.g. sda1, "" if unknown
+	missing bool
+	errors  btrfsErrors
+}
+
+// btrfsErrors are the persistent error counters of a btrfs device, which
+// btrfs device stats -z resets
+type btrfsErrors struct {
+	write      uint64
+	read       uint64
+	flush      uint64
+	corruption uint64
+	generation uint64
+}
+
+// below reports whether any counter is lower than in base, as after a reset
+func (e btrfsErrors) below(base btrfsErrors) bool {
+	return e.write < base.write || e.read < base.read || e.flush < base.flush ||
+		e.corruption < base.corruption || e.generation < base.generation
+}
+
+// since returns the errors counted after base
+func (e btrfsErrors) since(base btrfsErrors) btrfsErrors {
+	return btrfsErrors{
+		write:      e.write - base.write,
+		read:       e.read - base.read,
+		flush:      e.flush - base.flush,
+		corruption: e.corruption - base.corruption,
+		generation: e.generation - base.generation,
+	}
+}
+
+// btrfsMember is the btrfs filesystem membership of a disk, as shown on
+// its LED
+type btrfsMember struct {
+	fs      string // label, or uuid for filesystems without one
+	missing int    // devices missing from the filesystem
+	device  string
+	errors  btrfsErrors // errors since monitoring started
+	failed  bool        // write or flush errors reached the threshold
+	warned  bool        // read, corruption or generation errors reached the threshold
+}
+
+// display returns the display state for the disk's filesystem membership.
+// btrfs filesystems use the same colors as ZFS pools.
+func (b btrfsMember) display() displayState {
+	switch {
+	case b.failed:
+		return displayZpoolFault
+	case b.warned:
+		return displayZpoolErrors
+	case b.missing > 0:
+		return displayPoolDegraded
+	}
+	return displayHealthy
+}
+
+// missing returns the number of devices missing from the filesystem
+func (fs btrfsFS) missing() int {
+	present, missing := 0, 0
+	for _, dev := range fs.devices {
+		if dev.missing {
+			missing++
+		} else {
+			present++
+		}
+	}
+	if fs.total-present > missing {
+		missing = fs.total - present
+	}
+	return missing
+}
+
+func (m *Monitor) btrfsCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckBtrfsInterval
+	if interval <= 0 {
+		interval = 60 // Default to 60 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkBtrfs(ctx)
+		}
+	}
+}
+
+func (m *Monitor) checkBtrfs(ctx context.Context) {
+	filesystems := m.readBtrfsFilesystems()
+	var err error
+	for i := range filesystems {
+		if statsErr := m.readBtrfsErrors(ctx, &filesystems[i]); statsErr != nil {
+			err = statsErr
+		}
+	}
+	m.noteMembersTimeout("btrfs", err, func(s *diskState) bool { return s.btrfs.fs != "" })
+	m.applyBtrfsStatus(filesystems)
+}
+
+// applyBtrfsStatus updates the btrfs membership of every mapped disk. The
+// error counters persist on disk, so only errors counted after monitoring
+// started are compared with the threshold, like kernel I/O errors.
+func (m *Monitor) applyBtrfsStatus(filesystems []btrfsFS) {
+	threshold := uint64(m.cfg.ZpoolErrorThreshold)
+	if threshold == 0 {
+		threshold = 1
+	}
+	if m.btrfsBase == nil {
+		m.btrfsBase = make(map[string]btrfsErrors)
+	}
+
+	// A disk with several btrfs partitions shows the worst of them
+	members := make(map[string]btrfsMember)
+	for _, fs := range filesystems {
+		name := fs.label
+		if name == "" {
+			name = fs.uuid
+		}
+		missing := fs.missing()
+
+		for _, dev := range fs.devices {
+			if dev.missing {
+				continue
+			}
+			key := fs.uuid + "/" + dev.devid
+			base, ok := m.btrfsBase[key]
+			if !ok || dev.errors.below(base) {
+				// Errors from before the service started, or counters
+				// reset with btrfs device stats -z, do not count
+				base = dev.errors
+				m.btrfsBase[key] = base
+			}
+
+			if dev.name == "" {
+				continue
+			}
+			ledNames := m.diskLEDs(blockdev.Disks(m.root, dev.name))
+			if len(ledNames) == 0 {
+				continue
+			}
+
+			e := dev.errors.since(base)
+			member := btrfsMember{
+				fs:      name,
+				missing: missing,
+				device:  dev.name,
+				errors:  e,
+				failed:  e.write >= threshold || e.flush >= threshold,
+				warned:  e.read >= threshold || e.corruption >= threshold || e.generation >= threshold,
+			}
+			for _, ledName := range ledNames {
+				if prev, seen := members[ledName]; !seen || member.display() > prev.display() {
+					members[ledName] = member
+				}
+			}
+		}
+	}
+
+	m.mu.RLock()
+	disks := make(map[string]*diskState, len(m.ledToDevice))
+	for ledName, device := range m.ledToDevice {
+		disks[ledName] = m.disks[device]
+	}
+	m.mu.RUnlock()
+
+	for ledName, state := range disks {
+		// Disks missing from the output are not (or no longer) in a btrfs
+		// filesystem
+		member := members[ledName]
+
+		state.mu.RLock()
+		prev := state.btrfs
+		state.mu.RUnlock()
+		if prev == member {
+			continue
+		}
+
+		m.update(state, func(s *diskState) {
+			s.btrfs = member
+		})
+
+		level, prevLevel := member.display(), prev.display()
+		if level == prevLevel {
+			continue
+		}
+
+		now := m.clock().Now().Format("2006-01-02 15:04:05")
+		e := member.errors
+		switch {
+		case level == displayZpoolFault:
+			log.Printf("BTRFS Disk failure detected on /dev/%s (filesystem %s, errors wr %d rd %d flush %d corrupt %d gen %d) at %s",
+				member.device, member.fs, e.write, e.read, e.flush, e.corruption, e.generation, now)
+			m.recordEvent(state, "btrfs", fmt.Sprintf("errors wr %d flush %d in filesystem %s", e.write, e.flush, member.fs))
+		case level != displayHealthy:
+			log.Printf("BTRFS warning on /dev/%s: %s (filesystem %s, %d missing, errors wr %d rd %d flush %d corrupt %d gen %d) at %s",
+				member.device, level, member.fs, member.missing, e.write, e.read, e.flush, e.corruption, e.generation, now)
+			if level == displayZpoolErrors {
+				m.recordEvent(state, "btrfs-errors", fmt.Sprintf("errors rd %d corrupt %d gen %d in filesystem %s", e.read, e.corruption, e.generation, member.fs))
+			}
+		case prevLevel == displayZpoolFault:
+			log.Printf("BTRFS Disk /dev/%s recovered at %s", state.device, now)
+			m.recordEvent(state, "btrfs-recovered", "filesystem "+prev.fs)
+		default:
+			log.Printf("BTRFS warning on /dev/%s cleared at %s", state.device, now)
+		}
+	}
+}
+
+// readBtrfsFilesystems lists the mounted btrfs filesystems and their
+// devices from /sys/fs/btrfs/<uuid>. sysfs links each present device by its
+// kernel name and each devid under devinfo, so the devid of a device is read
+// from its superblock.
+func (m *Monitor) readBtrfsFilesystems() []btrfsFS {
+	entries, err := os.ReadDir(m.sysPath("fs", "btrfs"))
+	if err != nil {
+		return nil
+	}
+	if m.btrfsDevids == nil {
+		m.btrfsDevids = make(map[string]string)
+	}
+
+	var filesystems []btrfsFS
+	for _, entry := range entries {
+		dir := m.sysPath("fs", "btrfs", entry.Name())
+		devids, err := os.ReadDir(filepath.Join(dir, "devinfo"))
+		if err != nil {
+			// Not a filesystem, e.g. features
+			continue
+		}
+		fs := btrfsFS{uuid: entry.Name(), label: readAttr(filepath.Join(dir, "label")), total: len(devids)}
+
+		present := make(map[string]string) // devid -> kernel name
+		names, _ := os.ReadDir(filepath.Join(dir, "devices"))
+		for _, name := range names {
+			key := fs.uuid + "/" + name.Name()
+			devid, ok := m.btrfsDevids[key]
+			if !ok {
+				if devid, err = readBtrfsDevid(m.devPath(name.Name())); err != nil {
+					log.Printf("Warning: Failed to read btrfs devid of /dev/%s: %v", name.Name(), err)
+					continue
+				}
+				// A device keeps its devid, so its superblock is only read once
+				m.btrfsDevids[key] = devid
+			}
+			present[devid] = name.Name()
+		}
+
+		for _, d := range devids {
+			fs.devices = append(fs.devices, btrfsDevice{
+				devid:   d.Name(),
+				name:    present[d.Name()],
+				missing: readAttr(filepath.Join(dir, "devinfo", d.Name(), "missing")) == "1",
+			})
+		}
+		sort.Slice(fs.devices, func(i, j int) bool {
+			a, _ := strconv.Atoi(fs.devices[i].devid)
+			b, _ := strconv.Atoi(fs.devices[j].devid)
+			return a < b
+		})
+		filesystems = append(filesystems, fs)
+	}
+	return filesystems
+}
+
+// readBtrfsDevid reads the devid of a btrfs device from its superblock
+func readBtrfsDevid(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, btrfsDevidOffset+8)
+	if _, err := f.ReadAt(buf, btrfsSuperOffset); err != nil {
+		return "", err
+	}
+	if string(buf[btrfsMagicOffset:btrfsMagicOffset+len(btrfsMagic)]) != btrfsMagic {
+		return "", fmt.Errorf("no btrfs superblock")
+	}
+	return strconv.FormatUint(binary.LittleEndian.Uint64(buf[btrfsDevidOffset:]), 10), nil
+}
+
+// readBtrfsErrors reads the error counters of each present device from
+// /sys/fs/btrfs/<uuid>/devinfo/<devid>/error_stats. Kernels before 5.14
+// have no error_stats, so there the counters come from btrfs device stats,
+// and a failure to run it is returned.
+func (m *Monitor) readBtrfsErrors(ctx context.Context, fs *btrfsFS) error {
+	var err error
+	for i := range fs.devices {
+		dev := &fs.devices[i]
+		if dev.missing {
+			continue
+		}
+
+		path := m.sysPath("fs", "btrfs", fs.uuid, "devinfo", dev.devid, "error_stats")
+		if stats, statsErr := os.ReadFile(path); statsErr == nil {
+			dev.errors = parseBtrfsErrors(string(stats))
+			continue
+		}
+		if dev.name == "" {
+			continue
+		}
+		// With a device path, btrfs device stats only prints that device
+		output, statsErr := m.runner().Output(ctx, "btrfs", "device", "stats", "/dev/"+dev.name)
+		if statsErr != nil {
+			err = statsErr
+			continue
+		}
+		dev.errors = parseBtrfsErrors(string(output))
+	}
+	return err
+}
+
+// parseBtrfsErrors parses error counters as error_stats has them
+// ("write_errs 0") or as btrfs device stats prints them
+// ("[/dev/sda1].write_io_errs 0")
+func parseBtrfsErrors(text string) btrfsErrors {
+	var e btrfsErrors
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		name := fields[0]
+		if i := strings.LastIndex(name, "]."); i >= 0 {
+			name = name[i+2:]
+		}
+		switch strings.TrimSuffix(strings.TrimSuffix(name, "_errs"), "_io") {
+		case "write":
+			e.write = n
+		case "read":
+			e.read = n
+		case "flush":
+			e.flush = n
+		case "corruption":
+			e.corruption = n
+		case "generation":
+			e.generation = n
+		}
+	}
+	return e
+}
@@ -0,0 +1,174 @@
+package diskmon
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+const (
+	btrfsDataUUID  = "3c1f6a2e-8d4b-4f5a-9e21-7b0c9d8e1f23"
+	btrfsOtherUUID = "9a8b7c6d-5e4f-4a3b-2c1d-0e9f8a7b6c5d"
+	btrfsNoErrors  = "write_errs 0\nread_errs 0\nflush_errs 0\ncorruption_errs 0\ngeneration_errs 0\n"
+)
+
+// buildFakeBtrfs creates the sysfs entries of a mounted btrfs filesystem and
+// the superblocks of its devices. devices maps each devid to the kernel name
+// of its device, "" for a missing device.
+func buildFakeBtrfs(t *testing.T, root, uuid, label string, devices map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, "sys", "fs", "btrfs", uuid)
+	writeFile(t, filepath.Join(dir, "label"), label+"\n")
+	for devid, name := range devices {
+		if name == "" {
+			writeFile(t, filepath.Join(dir, "devinfo", devid, "missing"), "1\n")
+			continue
+		}
+		writeFile(t, filepath.Join(dir, "devinfo", devid, "missing"), "0\n")
+		writeFile(t, filepath.Join(dir, "devinfo", devid, "error_stats"), btrfsNoErrors)
+		symlink(t, filepath.Join(root, "sys", "class", "block", name), filepath.Join(dir, "devices", name))
+
+		super := make([]byte, btrfsSuperOffset+0x1000)
+		copy(super[btrfsSuperOffset+btrfsMagicOffset:], btrfsMagic)
+		n, _ := strconv.ParseUint(devid, 10, 64)
+		binary.LittleEndian.PutUint64(super[btrfsSuperOffset+btrfsDevidOffset:], n)
+		writeFile(t, filepath.Join(root, "dev", name), string(super))
+	}
+}
+
+// writeBtrfsErrors sets the error counters of a btrfs device
+func writeBtrfsErrors(t *testing.T, root, uuid, devid, stats string) {
+	t.Helper()
+	writeFile(t, filepath.Join(root, "sys", "fs", "btrfs", uuid, "devinfo", devid, "error_stats"), stats)
+}
+
+func TestMonitor_ReadBtrfsFilesystems(t *testing.T) {
+	root := t.TempDir()
+	buildFakeBtrfs(t, root, btrfsDataUUID, "data", map[string]string{"1": "sda1", "2": "sdb1", "3": "sdc", "4": ""})
+	buildFakeBtrfs(t, root, btrfsOtherUUID, "", map[string]string{"1": "sdd2"})
+	writeFile(t, filepath.Join(root, "sys", "fs", "btrfs", "features", "raid1c34"), "0\n")
+	m := &Monitor{root: root}
+
+	filesystems := m.readBtrfsFilesystems()
+	if len(filesystems) != 2 {
+		t.Fatalf("readBtrfsFilesystems() returned %d filesystems, want 2: %+v", len(filesystems), filesystems)
+	}
+
+	data := filesystems[0]
+	if data.label != "data" || data.uuid != btrfsDataUUID || data.total != 4 || len(data.devices) != 4 {
+		t.Errorf("data = %+v", data)
+	}
+	for i, name := range []string{"sda1", "sdb1", "sdc"} {
+		if dev := data.devices[i]; dev.devid != strconv.Itoa(i+1) || dev.name != name || dev.missing {
+			t.Errorf("device %d = %+v, want devid %d on %s", i, dev, i+1, name)
+		}
+	}
+	if dev := data.devices[3]; dev.devid != "4" || !dev.missing || dev.name != "" {
+		t.Errorf("missing device = %+v", dev)
+	}
+	if missing := data.missing(); missing != 1 {
+		t.Errorf("data missing() = %d, want 1", missing)
+	}
+
+	other := filesystems[1]
+	if other.label != "" || len(other.devices) != 1 || other.devices[0].name != "sdd2" {
+		t.Errorf("unlabeled filesystem = %+v", other)
+	}
+	if missing := other.missing(); missing != 0 {
+		t.Errorf("unlabeled missing() = %d, want 0", missing)
+	}
+}
+
+func TestParseBtrfsErrors(t *testing.T) {
+	tests := map[string]btrfsErrors{
+		"write_errs 1\nread_errs 2\nflush_errs 3\ncorruption_errs 4\ngeneration_errs 5\n": {write: 1, read: 2, flush: 3, corruption: 4, generation: 5},
+		`[/dev/sda1].write_io_errs    0
+[/dev/sda1].read_io_errs     7
+[/dev/sda1].flush_io_errs    0
+[/dev/sda1].corruption_errs  12
+[/dev/sda1].generation_errs  0
+`: {read: 7, corruption: 12},
+		"": {},
+	}
+	for input, expected := range tests {
+		if result := parseBtrfsErrors(input); result != expected {
+			t.Errorf("parseBtrfsErrors(%q) = %+v, want %+v", input, result, expected)
+		}
+	}
+}
+
+func TestMonitor_CheckBtrfs(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0", serial: "WD-WCC7K7654321"},
+		{name: "sdc", hctl: "2:0:0:0"},
+		{name: "sdd", hctl: "3:0:0:0"},
+	})
+	buildFakeBtrfs(t, root, btrfsDataUUID, "data", map[string]string{"1": "sda1", "2": "sdb1", "3": "sdc", "4": ""})
+	buildFakeBtrfs(t, root, btrfsOtherUUID, "", map[string]string{"1": "sdd2"})
+	// Errors from before the service started are ignored
+	writeBtrfsErrors(t, root, btrfsOtherUUID, "1", "write_errs 0\nread_errs 0\nflush_errs 0\ncorruption_errs 8\ngeneration_errs 0\n")
+
+	cfg := hotplugConfig()
+	cfg.CheckBtrfs = true
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 0}
+	cfg.ColorZpoolPoolDegraded = config.RGB{R: 255, G: 255, B: 0}
+	cfg.ColorZpoolErrors = config.RGB{R: 255, G: 64, B: 0}
+	cfg.ZpoolErrorThreshold = 1
+	m := newTestMonitor(t, root, cfg)
+	store, _ := openHistory(t)
+	m.history = store
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkBtrfs(context.Background())
+	writeBtrfsErrors(t, root, btrfsDataUUID, "1", "write_errs 0\nread_errs 0\nflush_errs 0\ncorruption_errs 3\ngeneration_errs 0\n")
+	writeBtrfsErrors(t, root, btrfsDataUUID, "2", "write_errs 2\nread_errs 0\nflush_errs 0\ncorruption_errs 0\ngeneration_errs 0\n")
+	m.checkBtrfs(context.Background())
+
+	expected := map[string]struct {
+		shown displayState
+		color string
+	}{
+		"sda": {displayZpoolErrors, "255 64 0"},
+		"sdb": {displayZpoolFault, "255 0 0"},
+		"sdc": {displayPoolDegraded, "255 255 0"},
+		"sdd": {displayHealthy, "255 255 255"},
+	}
+	for device, want := range expected {
+		if state := m.disks[device]; state.shown != want.shown {
+			t.Errorf("%s shown = %v, want %v", device, state.shown, want.shown)
+		}
+		if color := readLED(t, root, m.deviceToLED[device], "color"); color != want.color {
+			t.Errorf("%s color = %q, want %q", device, color, want.color)
+		}
+	}
+
+	// Resetting the counters and replacing the missing device clears it all
+	os.RemoveAll(filepath.Join(root, "sys", "fs", "btrfs", btrfsDataUUID))
+	buildFakeBtrfs(t, root, btrfsDataUUID, "data", map[string]string{"1": "sda1", "2": "sdb1", "3": "sdc"})
+	m.checkBtrfs(context.Background())
+	for device := range expected {
+		if state := m.disks[device]; state.shown != displayHealthy {
+			t.Errorf("%s shown = %v after recovery, want %v", device, state.shown, displayHealthy)
+		}
+	}
+	events := store.Events("WD-WCC7K7654321")
+	if len(events) != 2 || events[0].Kind != "btrfs" || events[0].Detail != "errors wr 2 flush 0 in filesystem data" || events[1].Kind != "btrfs-recovered" {
+		t.Errorf("Events() = %+v, want sdb failing and recovering in data", events)
+	}
+
+	// Errors after a reset count again
+	writeBtrfsErrors(t, root, btrfsDataUUID, "3", "write_errs 0\nread_errs 1\nflush_errs 0\ncorruption_errs 0\ngeneration_errs 0\n")
+	m.checkBtrfs(context.Background())
+	if state := m.disks["sdc"]; state.shown != displayZpoolErrors {
+		t.Errorf("sdc shown = %v after a read error, want %v", state.shown, displayZpoolErrors)
+	}
+}
@@ -0,0 +1,220 @@
+package diskmon
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/blockdev"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/zpool"
+)
+
+// mount is a mounted filesystem from /proc/self/mountinfo
+type mount struct {
+	point  string
+	fsType string
+	source string
+}
+
+func (m *Monitor) capacityCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckCapacityInterval
+	if interval <= 0 {
+		interval = 300 // Default to 300 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	// Show capacity right away rather than after the first interval
+	m.checkCapacity(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkCapacity(ctx)
+		}
+	}
+}
+
+func (m *Monitor) checkCapacity(ctx context.Context) {
+	usage := m.mountCapacities()
+
+	// Pools that are not mounted, or whose datasets share the pool's space,
+	// are measured by zpool list
+	if pools, err := zpool.Capacity(ctx, m.runner()); err == nil && len(pools) > 0 {
+		if vdevs, err := zpool.Status(ctx, m.runner(), m.root); err == nil {
+			poolCapacities(usage, pools, vdevs)
+		}
+	}
+
+	m.applyCapacity(usage)
+}
+
+// applyCapacity shows how full each mapped disk is, given the percentage of
+// space used on the fullest filesystem or pool of each disk
+func (m *Monitor) applyCapacity(usage map[string]int) {
+	for _, state := range m.snapshotDisks() {
+		state.mu.RLock()
+		device := state.device
+		wasLevel := m.capacityLevel(state.capacity)
+		state.mu.RUnlock()
+
+		capacity := usage[device]
+		m.update(state, func(s *diskState) {
+			s.capacity = capacity
+		})
+
+		level := m.capacityLevel(capacity)
+		if level == wasLevel {
+			continue
+		}
+		timestamp := m.clock().Now().Format("2006-01-02 15:04:05")
+		switch level {
+		case 2:
+			log.Printf("Disk /dev/%s is %d%% full, above the critical threshold at %s", device, capacity, timestamp)
+		case 1:
+			log.Printf("Disk /dev/%s is %d%% full, above the warning threshold at %s", device, capacity, timestamp)
+		default:
+			log.Printf("Disk /dev/%s is back to %d%% full at %s", device, capacity, timestamp)
+		}
+	}
+}
+
+// capacityLevel returns 2 if a disk is fuller than CapacityCrit, 1 if it is
+// fuller than CapacityWarn and 0 otherwise
+func (m *Monitor) capacityLevel(capacity int) int {
+	switch {
+	case m.cfg.CapacityCrit > 0 && capacity >= m.cfg.CapacityCrit:
+		return 2
+	case m.cfg.CapacityWarn > 0 && capacity >= m.cfg.CapacityWarn:
+		return 1
+	}
+	return 0
+}
+
+// idleColor returns the color of a healthy disk: its capacity color once it
+// passes CapacityWarn, else its temperature color. The caller must hold
+// s.mu.
+func (m *Monitor) idleColor(s *diskState) config.RGB {
+	if m.cfg.CheckCapacity {
+		switch m.capacityLevel(s.capacity) {
+		case 2:
+			return m.cfg.ColorCapacityCrit
+		case 1:
+			return m.cfg.ColorCapacityWarn
+		}
+	}
+	return m.temperatureColor(s.temperature)
+}
+
+// mountCapacities returns the percentage of space used on the fullest
+// mounted filesystem of each physical disk
+func (m *Monitor) mountCapacities() map[string]int {
+	usage := make(map[string]int)
+
+	data, err := os.ReadFile(m.procPath("self", "mountinfo"))
+	if err != nil {
+		return usage
+	}
+
+	measured := make(map[string]int)
+	for _, mnt := range parseMountinfo(string(data)) {
+		// ZFS datasets share their pool's space and are measured by
+		// zpool list
+		if mnt.fsType == "zfs" || !strings.HasPrefix(mnt.source, "/dev/") {
+			continue
+		}
+		// Bind mounts and btrfs subvolumes show the same filesystem
+		percent, ok := measured[mnt.source]
+		if !ok {
+			percent, ok = statfsCapacity(filepath.Join(m.root, mnt.point))
+			if !ok {
+				continue
+			}
+			measured[mnt.source] = percent
+		}
+		for _, disk := range blockdev.PhysicalDisks(m.root, mnt.source) {
+			usage[disk] = max(usage[disk], percent)
+		}
+	}
+	return usage
+}
+
+// poolCapacities raises the usage of each pool member to its pool's
+// capacity
+func poolCapacities(usage map[string]int, pools map[string]int, vdevs []zpool.Vdev) {
+	for _, v := range vdevs {
+		percent, ok := pools[v.Pool]
+		if !ok {
+			continue
+		}
+		for _, disk := range v.Disks {
+			usage[disk] = max(usage[disk], percent)
+		}
+	}
+}
+
+// statfsCapacity returns the percentage of a filesystem's space that is
+// used, rounded up like df does
+func statfsCapacity(path string) (int, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	used := st.Blocks - st.Bfree
+	if used+st.Bavail == 0 {
+		return 0, false
+	}
+	return int(math.Ceil(float64(used) * 100 / float64(used+st.Bavail))), true
+}
+
+// parseMountinfo parses /proc/self/mountinfo
+func parseMountinfo(data string) []mount {
+	var mounts []mount
+	for _, line := range strings.Split(data, "\n") {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		before, after, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(before)
+		tail := strings.Fields(after)
+		if len(fields) < 5 || len(tail) < 2 {
+			continue
+		}
+		mounts = append(mounts, mount{
+			point:  unescapeMountPath(fields[4]),
+			fsType: tail[0],
+			source: unescapeMountPath(tail[1]),
+		})
+	}
+	return mounts
+}
+
+// unescapeMountPath decodes the octal escapes such as \040 for a space that
+// the kernel uses in mount paths
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
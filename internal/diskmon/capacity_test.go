@@ -0,0 +1,105 @@
+package diskmon
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/zpool"
+)
+
+const testMountinfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /proc rw,nosuid shared:12 - proc proc rw
+24 22 8:17 / /srv/media\040files rw,relatime shared:2 - xfs /dev/sdb1 rw
+25 22 8:1 /export /export rw,relatime shared:1 - ext4 /dev/sda1 rw
+26 22 0:45 / /tank rw,xattr shared:3 - zfs tank rw,xattr,posixacl
+`
+
+func TestParseMountinfo(t *testing.T) {
+	expected := []mount{
+		{point: "/", fsType: "ext4", source: "/dev/sda1"},
+		{point: "/proc", fsType: "proc", source: "proc"},
+		{point: "/srv/media files", fsType: "xfs", source: "/dev/sdb1"},
+		{point: "/export", fsType: "ext4", source: "/dev/sda1"},
+		{point: "/tank", fsType: "zfs", source: "tank"},
+	}
+	if result := parseMountinfo(testMountinfo); !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseMountinfo() = %+v, want %+v", result, expected)
+	}
+}
+
+func TestMonitor_MountCapacities(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	writeFile(t, filepath.Join(root, "proc", "self", "mountinfo"), testMountinfo)
+	m := &Monitor{root: root}
+
+	// The fake root is measured for /, the other mount points do not exist
+	usage := m.mountCapacities()
+	if len(usage) != 1 {
+		t.Fatalf("mountCapacities() = %v, want only sda", usage)
+	}
+	if percent, ok := usage["sda"]; !ok || percent < 0 || percent > 100 {
+		t.Errorf("mountCapacities()[sda] = %d, %v, want a percentage", percent, ok)
+	}
+}
+
+func TestPoolCapacities(t *testing.T) {
+	usage := map[string]int{"sda": 50, "sdc": 95}
+	vdevs := []zpool.Vdev{
+		{Pool: "tank", Disks: []string{"sda"}},
+		{Pool: "tank", Disks: []string{"sdb"}},
+		{Pool: "backup", Disks: []string{"sdc"}},
+		{Pool: "unlisted", Disks: []string{"sdd"}},
+	}
+	poolCapacities(usage, map[string]int{"tank": 83, "backup": 20}, vdevs)
+
+	expected := map[string]int{"sda": 83, "sdb": 83, "sdc": 95}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Errorf("poolCapacities() = %v, want %v", usage, expected)
+	}
+}
+
+func TestMonitor_ApplyCapacity(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.CheckCapacity = true
+	cfg.CapacityWarn = 80
+	cfg.CapacityCrit = 90
+	cfg.ColorCapacityWarn = config.RGB{R: 255, G: 255, B: 0}
+	cfg.ColorCapacityCrit = config.RGB{R: 255, G: 128, B: 0}
+	cfg.ColorSmartWarn = config.RGB{R: 255, G: 192, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	steps := []struct {
+		name  string
+		usage map[string]int
+		sda   string
+		sdb   string
+	}{
+		{name: "below warning", usage: map[string]int{"sda": 79, "sdb": 12}, sda: "255 255 255", sdb: "255 255 255"},
+		{name: "warning", usage: map[string]int{"sda": 80, "sdb": 12}, sda: "255 255 0", sdb: "255 255 255"},
+		{name: "critical", usage: map[string]int{"sda": 97, "sdb": 12}, sda: "255 128 0", sdb: "255 255 255"},
+		{name: "unmounted", usage: map[string]int{"sdb": 91}, sda: "255 255 255", sdb: "255 128 0"},
+	}
+	for _, step := range steps {
+		m.applyCapacity(step.usage)
+		if color := readLED(t, root, "disk1", "color"); color != step.sda {
+			t.Errorf("%s: disk1 color = %q, want %q", step.name, color, step.sda)
+		}
+		if color := readLED(t, root, "disk2", "color"); color != step.sdb {
+			t.Errorf("%s: disk2 color = %q, want %q", step.name, color, step.sdb)
+		}
+	}
+
+	// Capacity is only the idle color, warnings still show
+	m.update(m.disks["sdb"], func(s *diskState) { s.smartWarning = "5 Reallocated_Sector_Ct" })
+	if color := readLED(t, root, "disk2", "color"); color != "255 192 0" {
+		t.Errorf("disk2 color = %q with a SMART warning, want %q", color, "255 192 0")
+	}
+}
@@ -0,0 +1,98 @@
+package diskmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/control"
+)
+
+// controlLoop serves commands on the control socket
+func (m *Monitor) controlLoop(ctx context.Context) {
+	if err := control.Serve(ctx, m.cfg.ControlSocket, m.handleCommand); err != nil {
+		log.Printf("Warning: Control socket %s failed: %v", m.cfg.ControlSocket, err)
+	}
+}
+
+// handleCommand runs a command received on the control socket:
+//
+//	identify <target> [duration]
+//	clear [target]
+//	faults [target]
+//	ack [target]
+func (m *Monitor) handleCommand(args []string) (string, error) {
+	switch args[0] {
+	case "identify":
+		if len(args) < 2 || len(args) > 3 {
+			return "", errors.New("usage: identify <device|serial|wwn|vdev> [duration]")
+		}
+		var timeout time.Duration
+		if len(args) == 3 {
+			var err error
+			if timeout, err = parseTimeout(args[2]); err != nil {
+				return "", err
+			}
+		}
+		devices, until, err := m.identify(args[1], timeout)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("identifying %s until %s", strings.Join(devices, " "), until.Format("15:04:05")), nil
+	case "clear":
+		target, err := optionalTarget(args)
+		if err != nil {
+			return "", err
+		}
+		devices, err := m.clearIdentify(target)
+		if err != nil {
+			return "", err
+		}
+		if len(devices) == 0 {
+			return "no disk is being identified", nil
+		}
+		return "cleared " + strings.Join(devices, " "), nil
+	case "faults":
+		target, err := optionalTarget(args)
+		if err != nil {
+			return "", err
+		}
+		return m.faultHistory(target)
+	case "ack":
+		target, err := optionalTarget(args)
+		if err != nil {
+			return "", err
+		}
+		devices, busy, err := m.acknowledge(target)
+		if err != nil {
+			return "", err
+		}
+		var lines []string
+		if len(devices) > 0 {
+			lines = append(lines, "acknowledged faults of "+strings.Join(devices, " "))
+		}
+		if len(busy) > 0 {
+			lines = append(lines, "busy with a SMART or standby check, acknowledge again: "+strings.Join(busy, " "))
+		}
+		if len(lines) == 0 {
+			return "no disk has latched faults", nil
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", fmt.Errorf("unknown command %q", args[0])
+}
+
+// optionalTarget returns the target of a command that applies to all disks
+// without one
+func optionalTarget(args []string) (string, error) {
+	switch len(args) {
+	case 1:
+		return "", nil
+	case 2:
+		return args[1], nil
+	}
+	return "", fmt.Errorf("usage: %s [device|serial|wwn|vdev]", args[0])
+}
@@ -5,44 +5,113 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/clock"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/history"
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/led"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/zpool"
 )
 
 type diskState struct {
 	led           *led.LED
 	device        string
-	lastStat      string
-	zpoolFaulted  bool
+	sampled       bool       // lastIO holds a sample
+	lastIO        ioCounters // request counters of the last I/O sample
+	lastIOAt      time.Time  // when lastIO was sampled
+	activity      ioActivity // kind of I/O seen by the last check
+	load          float64    // how busy the disk was at the last check, from 0 to 1
+	latencyIO     ioCounters // counters of the last latency sample
+	latency       float64    // average milliseconds per request
+	stallSince    time.Time  // start of I/O in flight without completions, zero if there is none
+	slow          string     // why the disk is slow, "" if it is not
+	ioErrBase     uint64     // ioerr_cnt when monitoring started, or when earlier errors were forgotten
+	ioErrBaseSet  bool
+	ioErrSeen     uint64     // ioerr_cnt at the last check
+	ioErrSince    time.Time  // when ioerr_cnt last rose
+	ownIO         sync.Mutex // held while the service sends its own commands to the disk
+	ioWarning     string     // reasons for an I/O error warning, "" if there is none
+	ioFault       string     // reasons for an I/O error failure, "" if there is none
+	zpool         zpoolMember // pool membership, zero if not in a pool
+	md            mdMember    // md array membership, zero if not in an array
+	btrfs         btrfsMember // btrfs membership, zero if not in a filesystem
 	smartFailed   bool
+	smartChecked  time.Time // time of the last SMART check
+	smartWarning  string    // reasons for a SMART warning, "" if there is none
+	smartWarnings []string  // attributes past a warning threshold at the last check
+	smartDeltas   map[int]string // attributes that rose too fast, kept until restart or ack
+	smartRaw      map[int]uint64 // raw SMART attribute values of the last check
+	temperature   int  // degrees Celsius, 0 if unknown
+	tempCritical  bool // temperature reached TemperatureCrit
+	capacity      int  // percent used of the fullest filesystem or pool on the disk
 	offline       bool
 	standby       bool
+	identifyUntil time.Time // end of identify mode, zero if the bay is not being identified
+	serial        string       // serial number or WWN in the fault history, "" if the disk has neither
+	latched       displayState // most severe latched fault, shown as the fault itself
+	wasFaulted    displayState // most severe latched fault, shown dimmed once the disk recovered
+	timedOut      []string     // checks whose last run timed out, e.g. "smart"
+	shown         displayState
+	look          ledLook
 	mu            sync.RWMutex
 }
 
+// diskSlot is a front panel bay: its LED and the mapping key of the disk in it
+type diskSlot struct {
+	led string
+	key string
+}
+
 type Monitor struct {
 	cfg          *config.DiskMonitorConfig
+	root         string                 // filesystem root for /sys and /dev, empty for the host
 	disks        map[string]*diskState // device -> state
 	ledToDevice  map[string]string      // LED name -> device
 	deviceToLED  map[string]string      // device -> LED name
 	zpoolLEDMap  map[string]string      // zpool device -> LED name
+	zpoolScans   map[string]zpool.Scan  // pool -> last scan status
+	mdSyncs      map[string]string      // md array -> last sync action
+	btrfsBase    map[string]btrfsErrors // uuid/devid -> error counters when monitoring started
+	btrfsDevids  map[string]string      // uuid/kernel name -> devid
+	diskstats    *diskstatsSampler      // used by the I/O loop only
+	latencies    []float64              // reused by checkLatency
+	history      *history.Store         // fault history, nil if disabled
+	commands     command.Runner         // runs external tools, the host's if nil
+	clk          clock.Clock            // drives the loops, the system clock if nil
+	slots        []diskSlot
 	mu           sync.RWMutex
 }
 
+// clock returns the clock the monitor reads the time from
+func (m *Monitor) clock() clock.Clock {
+	if m.clk == nil {
+		return clock.Real
+	}
+	return m.clk
+}
+
 func Run(ctx context.Context, cfg *config.DiskMonitorConfig) error {
 	m := &Monitor{
 		cfg:         cfg,
+		root:        cfg.Root,
 		disks:       make(map[string]*diskState),
 		ledToDevice: make(map[string]string),
 		deviceToLED: make(map[string]string),
 		zpoolLEDMap: make(map[string]string),
+		commands:    command.NewExec(cfg.CommandTimeouts),
+	}
+
+	if cfg.StateFile != "" {
+		store, err := history.Open(cfg.StateFile)
+		if err != nil {
+			log.Printf("Warning: Failed to load fault history, faults will not be kept: %v", err)
+		} else {
+			m.history = store
+		}
 	}
 
 	// Enumerate disks and initialize LEDs
@@ -52,7 +121,7 @@ func Run(ctx context.Context, cfg *config.DiskMonitorConfig) error {
 
 	// Build zpool mapping if enabled
 	if cfg.CheckZpool {
-		if err := m.buildZpoolMapping(); err != nil {
+		if err := m.buildZpoolMapping(ctx); err != nil {
 			log.Printf("Warning: Failed to build zpool mapping: %v", err)
 		}
 	}
@@ -77,6 +146,60 @@ func Run(ctx context.Context, cfg *config.DiskMonitorConfig) error {
 		}()
 	}
 
+	// Start md RAID check loop
+	if cfg.CheckMdraid {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.mdraidCheckLoop(ctx)
+		}()
+	}
+
+	// Start btrfs check loop
+	if cfg.CheckBtrfs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.btrfsCheckLoop(ctx)
+		}()
+	}
+
+	// Start I/O error check loop
+	if cfg.CheckIOErrors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.ioErrorCheckLoop(ctx)
+		}()
+	}
+
+	// Start temperature check loop
+	if cfg.CheckTemperature {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.temperatureCheckLoop(ctx)
+		}()
+	}
+
+	// Start capacity check loop
+	if cfg.CheckCapacity {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.capacityCheckLoop(ctx)
+		}()
+	}
+
+	// Start standby check loop
+	if cfg.CheckStandby {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.standbyCheckLoop(ctx)
+		}()
+	}
+
 	// Start disk online check loop
 	wg.Add(1)
 	go func() {
@@ -84,6 +207,28 @@ func Run(ctx context.Context, cfg *config.DiskMonitorConfig) error {
 		m.diskOnlineCheckLoop(ctx)
 	}()
 
+	// Start hotplug loop
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.hotplugLoop(ctx)
+	}()
+
+	// Start control socket and identify timeout loop
+	if cfg.ControlSocket != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.controlLoop(ctx)
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.identifyCheckLoop(ctx)
+		}()
+	}
+
 	// Start I/O monitoring loop
 	wg.Add(1)
 	go func() {
@@ -98,12 +243,6 @@ func Run(ctx context.Context, cfg *config.DiskMonitorConfig) error {
 func (m *Monitor) initializeDisks() error {
 	ledMap := []string{"disk1", "disk2", "disk3", "disk4", "disk5", "disk6", "disk7", "disk8"}
 
-	// Enumerate disks based on mapping method
-	devMap, err := m.enumerateDisks()
-	if err != nil {
-		return err
-	}
-
 	// Get mapping array based on method
 	var mapping []string
 	switch m.cfg.MappingMethod {
@@ -122,323 +261,267 @@ func (m *Monitor) initializeDisks() error {
 				mapping = []string{"2:0:0:0", "3:0:0:0", "4:0:0:0", "5:0:0:0", "0:0:0:0", "1:0:0:0"}
 			}
 		}
-	case "serial":
-		// Serial mapping comes from environment variable DISK_SERIAL
-		serialEnv := os.Getenv("DISK_SERIAL")
-		if serialEnv != "" {
-			mapping = strings.Fields(serialEnv)
-		} else {
-			return fmt.Errorf("serial mapping method requires DISK_SERIAL environment variable")
+	case "nvme":
+		mapping = m.cfg.DiskMapping
+		if len(mapping) == 0 {
+			mapping = []string{"nvme0", "nvme1", "nvme2", "nvme3", "nvme4", "nvme5", "nvme6", "nvme7"}
+		}
+	case "serial", "wwn", "by-id", "by-path":
+		mapping = m.cfg.DiskMapping
+		if len(mapping) == 0 && m.cfg.MappingMethod == "serial" {
+			// Older configurations pass serials through the DISK_SERIAL environment variable
+			mapping = strings.Fields(os.Getenv("DISK_SERIAL"))
+		}
+		if len(mapping) == 0 {
+			return fmt.Errorf("%s mapping method requires DISK_MAPPING to be set", m.cfg.MappingMethod)
+		}
+		if m.cfg.MappingMethod == "wwn" {
+			wwns := make([]string, len(mapping))
+			for i, key := range mapping {
+				wwns[i] = normalizeWWN(key)
+			}
+			mapping = wwns
 		}
 	default:
 		return fmt.Errorf("unsupported mapping method: %s", m.cfg.MappingMethod)
 	}
 
-	// Initialize LEDs
+	m.slots = m.slots[:0]
 	for i, ledName := range ledMap {
 		if i >= len(mapping) {
 			break
 		}
+		m.slots = append(m.slots, diskSlot{led: ledName, key: mapping[i]})
 
-		l := led.NewLED(ledName)
-		if !l.Exists() {
-			continue
+		// Slots stay dark until a disk is mapped to them
+		l := m.newLED(ledName)
+		if l.Exists() {
+			l.SetBrightness(0)
+			l.SetTrigger("none")
 		}
+	}
+
+	return m.refreshDisks()
+}
 
-		// Initialize LED
-		if err := l.SetTrigger("oneshot"); err != nil {
-			log.Printf("Warning: Failed to set trigger for %s: %v", ledName, err)
+// refreshDisks maps the disks currently present to their slots. It runs at
+// startup and again whenever disks are added or removed: new disks get their
+// LED initialized, and disks that come back after going offline are reset.
+func (m *Monitor) refreshDisks() error {
+	devMap, err := m.enumerateDisks()
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range m.slots {
+		l := m.newLED(slot.led)
+		if !l.Exists() {
 			continue
 		}
-		l.SetInvert(1)
-		l.SetDelayOn(100)
-		l.SetDelayOff(100)
-		l.SetColor(m.cfg.ColorDiskHealth.R, m.cfg.ColorDiskHealth.G, m.cfg.ColorDiskHealth.B)
-		l.SetBrightness(m.cfg.BrightnessDiskLeds)
 
 		// Find corresponding device
-		key := mapping[i]
-		device, ok := devMap[key]
+		device, ok := devMap[slot.key]
 		if !ok {
-			// No disk in this slot
-			l.SetBrightness(0)
-			l.SetTrigger("none")
+			// No disk in this slot, a mapped disk is flagged by checkDiskOnline
 			continue
 		}
 
 		// Check if device exists
-		if _, err := os.Stat(filepath.Join("/sys/class/block", device, "stat")); err != nil {
-			// Device doesn't exist
-			l.SetBrightness(0)
-			l.SetTrigger("none")
+		if _, err := os.Stat(m.sysPath("class/block", device, "stat")); err != nil {
 			continue
 		}
 
-		// Store mappings
-		m.mu.Lock()
-		m.ledToDevice[ledName] = device
-		m.deviceToLED[device] = ledName
-		m.disks[device] = &diskState{
-			led:    l,
-			device: device,
-		}
-		m.mu.Unlock()
-
-		log.Printf("Mapped %s -> %s -> %s", m.cfg.MappingMethod, key, device)
-	}
-
-	return nil
-}
-
-func (m *Monitor) enumerateDisks() (map[string]string, error) {
-	devMap := make(map[string]string)
-
-	switch m.cfg.MappingMethod {
-	case "ata":
-		// List /sys/block and find ata devices
-		entries, err := os.ReadDir("/sys/block")
-		if err != nil {
-			return nil, err
-		}
-
-		ataRegex := regexp.MustCompile(`ata\d+`)
-		for _, entry := range entries {
-			linkPath := filepath.Join("/sys/block", entry.Name())
-			linkTarget, err := os.Readlink(linkPath)
-			if err != nil {
-				continue
-			}
+		m.mu.RLock()
+		current, mapped := m.ledToDevice[slot.led]
+		owner, owned := m.deviceToLED[device]
+		state := m.disks[current]
+		m.mu.RUnlock()
 
-			matches := ataRegex.FindString(linkTarget)
-			if matches != "" {
-				devMap[matches] = entry.Name()
+		if mapped && current == device {
+			state.mu.RLock()
+			wasOffline := state.offline
+			state.mu.RUnlock()
+
+			if wasOffline {
+				// The disk came back, possibly after a reset of its LED
+				m.setupDiskLED(l)
+				m.update(state, func(s *diskState) {
+					s.offline = false
+					s.look = m.baseLook()
+				})
+				log.Printf("Disk /dev/%s is back online at %s", device, m.clock().Now().Format("2006-01-02 15:04:05"))
+				m.recordEvent(state, "online", "")
 			}
+			continue
 		}
 
-	case "hctl", "serial":
-		// Use lsblk to enumerate
-		cmd := exec.Command("lsblk", "-S", "-o", "name,"+m.cfg.MappingMethod+",tran")
-		output, err := cmd.Output()
-		if err != nil {
-			return nil, fmt.Errorf("failed to run lsblk: %w", err)
+		if owned && owner != slot.led {
+			// The kernel reused the name of a disk removed from another slot
+			m.unmapDisk(owner)
+			other := m.newLED(owner)
+			other.SetBrightness(0)
+			other.SetTrigger("none")
 		}
-
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if !strings.Contains(line, "sata") {
-				continue
-			}
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				devMap[fields[1]] = fields[0]
-			}
+		if mapped {
+			m.unmapDisk(slot.led)
+			log.Printf("Disk /dev/%s in %s replaced by /dev/%s", current, slot.led, device)
 		}
-	}
-
-	return devMap, nil
-}
 
-func (m *Monitor) getProductName() string {
-	cmd := exec.Command("dmidecode", "--string", "system-product-name")
-	output, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(output))
-}
-
-func (m *Monitor) buildZpoolMapping() error {
-	cmd := exec.Command("zpool", "status", "-L")
-	output, err := cmd.Output()
-	if err != nil {
-		return fmt.Errorf("failed to run zpool status: %w", err)
-	}
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "sd") && !strings.HasPrefix(line, "dm") {
+		if err := m.setupDiskLED(l); err != nil {
+			log.Printf("Warning: Failed to set trigger for %s: %v", slot.led, err)
 			continue
 		}
 
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
+		// Store mappings
+		m.mu.Lock()
+		m.ledToDevice[slot.led] = device
+		m.deviceToLED[device] = slot.led
+		state = &diskState{
+			led:    l,
+			device: device,
+			serial: m.diskKey(device),
+			look:   m.baseLook(),
 		}
+		m.disks[device] = state
+		m.mu.Unlock()
+		m.restoreLatched(state)
 
-		zpoolDev := fields[0]
-		baseDev := regexp.MustCompile(`\d+$`).ReplaceAllString(zpoolDev, "")
-
-		m.mu.RLock()
-		ledName, ok := m.deviceToLED[baseDev]
-		m.mu.RUnlock()
-
-		if ok {
-			m.mu.Lock()
-			m.zpoolLEDMap[zpoolDev] = ledName
-			if zpoolDev != baseDev {
-				m.zpoolLEDMap[baseDev] = ledName
-			}
-			m.mu.Unlock()
-			if m.cfg.DebugZpool {
-				log.Printf("zpool device %s -> %s -> LED: %s", zpoolDev, baseDev, ledName)
-			}
-		}
+		log.Printf("Mapped %s -> %s -> %s", m.cfg.MappingMethod, slot.key, device)
 	}
 
 	return nil
 }
 
-func (m *Monitor) smartCheckLoop(ctx context.Context) {
-	interval := m.cfg.CheckSmartInterval
-	if interval <= 0 {
-		interval = 360 // Default to 360 seconds if invalid
+// setupDiskLED puts a slot LED into oneshot mode with the health color
+func (m *Monitor) setupDiskLED(l *led.LED) error {
+	if err := l.SetTrigger("oneshot"); err != nil {
+		return err
 	}
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
-	defer ticker.Stop()
+	l.SetInvert(1)
+	l.SetDelayOn(100)
+	l.SetDelayOff(100)
+	l.SetColor(m.cfg.ColorDiskHealth.R, m.cfg.ColorDiskHealth.G, m.cfg.ColorDiskHealth.B)
+	l.SetBrightness(m.cfg.BrightnessDiskLeds)
+	return nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			m.checkSMART()
+// unmapDisk removes the disk mapped to an LED
+func (m *Monitor) unmapDisk(ledName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	device := m.ledToDevice[ledName]
+	delete(m.ledToDevice, ledName)
+	delete(m.deviceToLED, device)
+	delete(m.disks, device)
+	for zpoolDev, name := range m.zpoolLEDMap {
+		if name == ledName {
+			delete(m.zpoolLEDMap, zpoolDev)
 		}
 	}
 }
 
-func (m *Monitor) checkSMART() {
+// diskLEDs returns the LEDs of the mapped disks among disks, as resolved by
+// the blockdev package
+func (m *Monitor) diskLEDs(disks []string) []string {
 	m.mu.RLock()
-	disks := make([]*diskState, 0, len(m.disks))
-	for _, state := range m.disks {
-		disks = append(disks, state)
-	}
-	m.mu.RUnlock()
-
-	for _, state := range disks {
-		state.mu.RLock()
-		ledColor := state.led
-		isHealthy := !state.smartFailed && !state.zpoolFaulted && !state.offline
-		device := state.device
-		state.mu.RUnlock()
+	defer m.mu.RUnlock()
 
-		if !isHealthy {
-			continue // Skip if already in error state
-		}
-
-		// Run smartctl
-		cmd := exec.Command("smartctl", "-H", "/dev/"+device, "-n", "standby,0")
-		err := cmd.Run()
-		ret := 0
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				ret = exitError.ExitCode()
-			}
-		}
-
-		// Check return code (bit 5 is standby, ignore it)
-		if ret&^32 != 0 {
-			state.mu.Lock()
-			state.smartFailed = true
-			state.mu.Unlock()
-
-			ledColor.SetColor(m.cfg.ColorSmartFail.R, m.cfg.ColorSmartFail.G, m.cfg.ColorSmartFail.B)
-			log.Printf("SMART Disk failure detected on /dev/%s at %s", device, time.Now().Format("2006-01-02 15:04:05"))
+	var ledNames []string
+	for _, disk := range disks {
+		if ledName, ok := m.deviceToLED[disk]; ok {
+			ledNames = append(ledNames, ledName)
 		}
 	}
+	return ledNames
 }
 
-func (m *Monitor) zpoolCheckLoop(ctx context.Context) {
-	interval := m.cfg.CheckZpoolInterval
+func (m *Monitor) smartCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckSmartInterval
 	if interval <= 0 {
-		interval = 5 // Default to 5 seconds if invalid
+		interval = 360 // Default to 360 seconds if invalid
 	}
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
 
-	faultedLogged := make(map[string]bool)
-
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			m.checkZpool(faultedLogged)
+		case <-ticker.C():
+			m.checkSMART(ctx)
 		}
 	}
 }
 
-func (m *Monitor) checkZpool(faultedLogged map[string]bool) {
-	cmd := exec.Command("zpool", "status", "-L")
-	output, err := cmd.Output()
-	if err != nil {
-		return
+func (m *Monitor) checkSMART(ctx context.Context) {
+	failedInterval := m.cfg.CheckSmartFailedInterval
+	if failedInterval <= 0 {
+		failedInterval = 3600 // Default to an hour if invalid
 	}
+	now := m.clock().Now()
 
-	lines := strings.Split(string(output), "\n")
-	seenDevices := make(map[string]bool)
+	for _, state := range m.snapshotDisks() {
+		state.mu.RLock()
+		device := state.device
+		offline := state.offline
+		failed := state.smartFailed
+		warning := state.smartWarning
+		lastChecked := state.smartChecked
+		prevRaw := state.smartRaw
+		state.mu.RUnlock()
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "sd") && !strings.HasPrefix(line, "dm") {
+		if offline {
 			continue
 		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
+		// Failed disks are re-checked at a slower cadence so they can recover
+		if failed && now.Sub(lastChecked) < time.Duration(failedInterval)*time.Second {
 			continue
 		}
 
-		zpoolDev := fields[0]
-		state := strings.TrimSpace(fields[1])
-		seenDevices[zpoolDev] = true
-
-		// Find LED for this device
-		m.mu.RLock()
-		ledName, ok := m.zpoolLEDMap[zpoolDev]
-		if !ok {
-			baseDev := regexp.MustCompile(`\d+$`).ReplaceAllString(zpoolDev, "")
-			ledName, ok = m.zpoolLEDMap[baseDev]
-			if !ok {
-				ledName, ok = m.deviceToLED[baseDev]
-			}
+		result, err := m.smartStatus(ctx, device, prevRaw)
+		m.noteTimeout(state, "smart", err)
+		if err != nil {
+			log.Printf("Warning: Failed to check SMART status of /dev/%s: %v", device, err)
+			continue
 		}
-		m.mu.RUnlock()
-
-		if !ok {
-			if m.cfg.DebugZpool {
-				log.Printf("WARNING: ZPOOL device /dev/%s not found in LED mapping", zpoolDev)
-			}
+		if result.skipped {
 			continue
 		}
 
-		l := led.NewLED(ledName)
-		currentColor, _ := l.Read("color")
-
-		switch state {
-		case "OFFLINE", "FAULTED", "UNAVAIL", "REMOVED", "CORRUPT":
-			// Set to failure color
-			l.SetColor(m.cfg.ColorZpoolFail.R, m.cfg.ColorZpoolFail.G, m.cfg.ColorZpoolFail.B)
-
-			// Log once per faulted device
-			if !faultedLogged[zpoolDev] {
-				if m.cfg.DebugZpool {
-					log.Printf("ZPOOL Disk failure detected on /dev/%s (state: %s) -> LED: %s at %s", zpoolDev, state, ledName, time.Now().Format("2006-01-02 15:04:05"))
-				} else {
-					log.Printf("ZPOOL Disk failure detected on /dev/%s (state: %s) at %s", zpoolDev, state, time.Now().Format("2006-01-02 15:04:05"))
-				}
-				faultedLogged[zpoolDev] = true
+		var newWarning string
+		m.update(state, func(s *diskState) {
+			// Attributes that rose too fast keep warning until the service
+			// restarts, the disk is replaced or the warning is acknowledged
+			if s.smartDeltas == nil {
+				s.smartDeltas = make(map[int]string)
 			}
-
-		case "ONLINE", "AVAIL", "DEGRADED":
-			// Reset if it was previously faulted
-			if currentColor == fmt.Sprintf("%d %d %d", m.cfg.ColorZpoolFail.R, m.cfg.ColorZpoolFail.G, m.cfg.ColorZpoolFail.B) {
-				l.SetColor(m.cfg.ColorDiskHealth.R, m.cfg.ColorDiskHealth.G, m.cfg.ColorDiskHealth.B)
-				if m.cfg.DebugZpool {
-					log.Printf("ZPOOL Disk /dev/%s recovered (state: %s) at %s", zpoolDev, state, time.Now().Format("2006-01-02 15:04:05"))
-				}
+			for id, reason := range result.deltas {
+				s.smartDeltas[id] = reason
 			}
-			delete(faultedLogged, zpoolDev)
+			s.smartFailed = len(result.failures) > 0
+			s.smartWarnings = result.warnings
+			s.smartWarning = smartReason(result.warnings, s.smartDeltas)
+			s.smartRaw = result.raw
+			s.smartChecked = now
+			newWarning = s.smartWarning
+		})
+
+		timestamp := now.Format("2006-01-02 15:04:05")
+		reason := smartReason(result.failures, nil)
+		switch {
+		case reason != "" && !failed:
+			log.Printf("SMART Disk failure detected on /dev/%s (%s) at %s", device, reason, timestamp)
+			m.recordEvent(state, "smart", reason)
+		case reason == "" && failed:
+			log.Printf("SMART Disk /dev/%s recovered at %s", device, timestamp)
+			m.recordEvent(state, "smart-recovered", "")
+		}
+		switch {
+		case newWarning != "" && newWarning != warning:
+			log.Printf("SMART warning on /dev/%s (%s) at %s", device, newWarning, timestamp)
+		case newWarning == "" && warning != "":
+			log.Printf("SMART warning on /dev/%s cleared at %s", device, timestamp)
 		}
 	}
 }
@@ -448,46 +531,43 @@ func (m *Monitor) diskOnlineCheckLoop(ctx context.Context) {
 	if interval <= 0 {
 		interval = 5 // Default to 5 seconds if invalid
 	}
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			m.checkDiskOnline()
 		}
 	}
 }
 
 func (m *Monitor) checkDiskOnline() {
-	m.mu.RLock()
-	disks := make([]*diskState, 0, len(m.disks))
-	for _, state := range m.disks {
-		disks = append(disks, state)
-	}
-	m.mu.RUnlock()
-
-	for _, state := range disks {
+	for _, state := range m.snapshotDisks() {
 		state.mu.RLock()
-		isHealthy := !state.smartFailed && !state.zpoolFaulted && !state.offline
 		device := state.device
-		ledColor := state.led
+		wasOffline := state.offline
 		state.mu.RUnlock()
 
-		if !isHealthy {
+		// Check if device still exists
+		_, err := os.Stat(m.sysPath("class/block", device, "stat"))
+		offline := err != nil
+		if offline == wasOffline {
 			continue
 		}
 
-		// Check if device still exists
-		if _, err := os.Stat(filepath.Join("/sys/class/block", device, "stat")); err != nil {
-			state.mu.Lock()
-			state.offline = true
-			state.mu.Unlock()
+		m.update(state, func(s *diskState) {
+			s.offline = offline
+		})
 
-			ledColor.SetColor(m.cfg.ColorDiskUnavail.R, m.cfg.ColorDiskUnavail.G, m.cfg.ColorDiskUnavail.B)
-			log.Printf("Disk /dev/%s went offline at %s", device, time.Now().Format("2006-01-02 15:04:05"))
+		if offline {
+			log.Printf("Disk /dev/%s went offline at %s", device, m.clock().Now().Format("2006-01-02 15:04:05"))
+			m.recordEvent(state, "offline", "")
+		} else {
+			log.Printf("Disk /dev/%s is back online at %s", device, m.clock().Now().Format("2006-01-02 15:04:05"))
+			m.recordEvent(state, "online", "")
 		}
 	}
 }
@@ -497,54 +577,104 @@ func (m *Monitor) ioMonitorLoop(ctx context.Context) {
 	if interval <= 0 {
 		interval = 0.1 // Default to 0.1 seconds if invalid
 	}
-	ticker := time.NewTicker(time.Duration(interval * float64(time.Second)))
+	ticker := m.clock().NewTicker(time.Duration(interval * float64(time.Second)))
 	defer ticker.Stop()
+	defer func() {
+		if m.diskstats != nil {
+			m.diskstats.close()
+		}
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			m.checkIO()
 		}
 	}
 }
 
 func (m *Monitor) checkIO() {
-	m.mu.RLock()
-	disks := make([]*diskState, 0, len(m.disks))
-	for _, state := range m.disks {
-		disks = append(disks, state)
+	if m.diskstats == nil {
+		m.diskstats = newDiskstatsSampler(m.procPath("diskstats"))
+	}
+	data, err := m.diskstats.read()
+	if err != nil {
+		return
 	}
-	m.mu.RUnlock()
-
-	for _, state := range disks {
-		state.mu.RLock()
-		isHealthy := !state.smartFailed && !state.zpoolFaulted && !state.offline
-		device := state.device
-		lastStat := state.lastStat
-		state.mu.RUnlock()
 
-		if !isHealthy {
-			continue
+	now := m.clock().Now()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	forEachDiskstat(data, func(name []byte, counters ioCounters) {
+		if state, ok := m.disks[string(name)]; ok {
+			if m.cfg.CheckLatency {
+				m.trackLatency(state, counters, now)
+			}
+			m.sampleIO(state, counters, now)
 		}
+	})
+	if m.cfg.CheckLatency {
+		m.checkLatency(now)
+	}
+}
 
-		// Read current stat
-		statPath := filepath.Join("/sys/class/block", device, "stat")
-		newStat, err := os.ReadFile(statPath)
-		if err != nil {
-			continue
-		}
+// sampleIO shows the I/O a disk did since its last sample
+func (m *Monitor) sampleIO(state *diskState, counters ioCounters, now time.Time) {
+	state.mu.RLock()
+	// Faults and scan patterns hide I/O activity
+	isFault := state.shown.isFault()
+	blinking := state.look.blink > 0
+	sampled := state.sampled
+	lastIO := state.lastIO
+	lastIOAt := state.lastIOAt
+	lastActivity := state.activity
+	lastLoad := state.load
+	state.mu.RUnlock()
+
+	if isFault || blinking {
+		return
+	}
 
-		newStatStr := string(newStat)
-		if newStatStr != lastStat {
-			// I/O activity detected
+	if sampled && counters == lastIO {
+		if lastActivity != ioIdle || lastLoad > 0 {
+			// Back to the idle look
+			m.update(state, func(s *diskState) {
+				s.lastIOAt = now
+				s.activity = ioIdle
+				s.load = 0
+			})
+		} else {
 			state.mu.Lock()
-			state.lastStat = newStatStr
+			state.lastIOAt = now
 			state.mu.Unlock()
-
-			state.led.TriggerShot()
 		}
+		return
 	}
-}
 
+	activity := ioRead
+	if m.cfg.SeparateReadWrite {
+		activity = counters.activity(lastIO, m.cfg.ShowDiscardFlush)
+	}
+	var load float64
+	if elapsed := now.Sub(lastIOAt).Seconds(); sampled && elapsed > 0 {
+		// Ticks run late under load, so the load is measured over the
+		// time that actually passed between the samples
+		load = m.ioLoad(lastIO, counters, elapsed)
+	}
+
+	// I/O activity detected, which also means the disk has spun up
+	m.update(state, func(s *diskState) {
+		s.sampled = true
+		s.lastIO = counters
+		s.lastIOAt = now
+		s.activity = activity
+		s.load = load
+		s.standby = false
+	})
+
+	if activity != ioIdle {
+		state.led.TriggerShot()
+	}
+}
@@ -0,0 +1,199 @@
+package diskmon
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/history"
+)
+
+// faultDisplay returns the display state of a latched fault kind
+func faultDisplay(kind string) displayState {
+	switch kind {
+	case "smart":
+		return displaySmartFail
+	case "zpool":
+		return displayZpoolFault
+	case "zpool-errors":
+		return displayZpoolErrors
+	case "md", "btrfs":
+		return displayZpoolFault
+	case "btrfs-errors":
+		return displayZpoolErrors
+	case "io":
+		return displayIOFault
+	case "offline":
+		return displayOffline
+	}
+	return displayHealthy
+}
+
+// latch keeps showing a fault until it is acknowledged, either as the fault
+// itself or dimmed once the disk recovered. The caller must hold s.mu.
+func (m *Monitor) latch(s *diskState, kind string) {
+	if m.cfg.LatchPattern == "dim" {
+		s.wasFaulted = max(s.wasFaulted, faultDisplay(kind))
+	} else {
+		s.latched = max(s.latched, faultDisplay(kind))
+	}
+}
+
+// diskKey returns the serial number the fault history knows a disk by, or
+// its WWN if it has no serial number
+func (m *Monitor) diskKey(device string) string {
+	if serial := m.diskSerial(device); serial != "" {
+		return serial
+	}
+	return m.diskWWN(device)
+}
+
+// restoreLatched shows the faults latched in the history of a newly mapped
+// disk, of the kinds LatchFaults still lists
+func (m *Monitor) restoreLatched(state *diskState) {
+	if m.history == nil || state.serial == "" {
+		return
+	}
+
+	var faults []history.Fault
+	for _, f := range m.history.Latched(state.serial) {
+		if slices.Contains(m.cfg.LatchFaults, f.Kind) {
+			faults = append(faults, f)
+		}
+	}
+	for _, f := range faults {
+		log.Printf("Disk /dev/%s has a latched %s fault (%s) since %s", state.device, f.Kind, f.Detail, f.Since.Local().Format("2006-01-02 15:04:05"))
+	}
+	if len(faults) > 0 {
+		m.update(state, func(s *diskState) {
+			for _, f := range faults {
+				m.latch(s, f.Kind)
+			}
+		})
+	}
+}
+
+// recordEvent adds an event to the fault history of a disk. Faults of a kind
+// listed in LatchFaults keep showing until they are acknowledged, even if
+// the disk recovers or the service restarts.
+func (m *Monitor) recordEvent(state *diskState, kind, detail string) {
+	latch := slices.Contains(m.cfg.LatchFaults, kind) && faultDisplay(kind) != displayHealthy
+	if latch {
+		m.update(state, func(s *diskState) {
+			m.latch(s, kind)
+		})
+	}
+
+	if m.history == nil {
+		return
+	}
+	state.mu.RLock()
+	device := state.device
+	serial := state.serial
+	state.mu.RUnlock()
+	if serial == "" {
+		// Without a serial number the disk cannot be told apart from
+		// the next one in its bay
+		return
+	}
+
+	e := history.Event{Time: m.clock().Now(), Device: device, Kind: kind, Detail: detail}
+	if err := m.history.Record(serial, e, latch); err != nil {
+		log.Printf("Warning: Failed to save fault history: %v", err)
+	}
+}
+
+// acknowledge ends the latched faults of the disks matching target, a disk
+// or a slot such as disk3, or of all disks if target is empty, along with
+// their warnings for SMART attributes that rose and for I/O errors. It
+// returns the disks that had any of them, and the disks it left alone
+// because the service was sending them a command.
+func (m *Monitor) acknowledge(target string) (devices, busy []string, err error) {
+	states := m.snapshotDisks()
+	if target != "" {
+		if states = m.resolveTarget(target); len(states) == 0 {
+			return nil, nil, fmt.Errorf("no mapped disk matches %q", target)
+		}
+	}
+
+	now := m.clock().Now()
+	for _, state := range states {
+		forgot, isBusy := m.forgetIOErrors(state)
+		state.mu.RLock()
+		device := state.device
+		serial := state.serial
+		latched := forgot || state.latched != displayHealthy || state.wasFaulted != displayHealthy || len(state.smartDeltas) > 0
+		state.mu.RUnlock()
+		if isBusy {
+			busy = append(busy, device)
+			continue
+		}
+
+		if m.history != nil && serial != "" {
+			stored, err := m.history.Acknowledge(serial, device, now)
+			if err != nil {
+				log.Printf("Warning: Failed to save fault history: %v", err)
+			}
+			latched = latched || stored
+		}
+		if !latched {
+			continue
+		}
+		m.update(state, func(s *diskState) {
+			s.latched = displayHealthy
+			s.wasFaulted = displayHealthy
+			s.smartDeltas = nil
+			s.smartWarning = smartReason(s.smartWarnings, nil)
+		})
+		devices = append(devices, device)
+		log.Printf("Faults and warnings of disk /dev/%s acknowledged at %s", device, now.Format("2006-01-02 15:04:05"))
+	}
+	sort.Strings(devices)
+	sort.Strings(busy)
+	return devices, busy, nil
+}
+
+// faultHistory describes the latched faults and events of the disks
+// matching target, or of all mapped disks if target is empty
+func (m *Monitor) faultHistory(target string) (string, error) {
+	if m.history == nil {
+		return "", fmt.Errorf("fault history is disabled")
+	}
+	states := m.snapshotDisks()
+	if target != "" {
+		if states = m.resolveTarget(target); len(states) == 0 {
+			return "", fmt.Errorf("no mapped disk matches %q", target)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].device < states[j].device })
+
+	var b strings.Builder
+	for _, state := range states {
+		state.mu.RLock()
+		device := state.device
+		serial := state.serial
+		state.mu.RUnlock()
+		if serial == "" {
+			fmt.Fprintf(&b, "%s: no serial number, history not kept\n", device)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s (%s):\n", device, serial)
+		if acked := m.history.Acknowledged(serial); !acked.IsZero() {
+			fmt.Fprintf(&b, "  acknowledged %s\n", acked.Local().Format("2006-01-02 15:04:05"))
+		}
+		for _, f := range m.history.Latched(serial) {
+			fmt.Fprintf(&b, "  latched %s since %s: %s\n", f.Kind, f.Since.Local().Format("2006-01-02 15:04:05"), f.Detail)
+		}
+		for _, e := range m.history.Events(serial) {
+			fmt.Fprintf(&b, "  %s %s %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Device, e.Kind)
+			if e.Detail != "" {
+				fmt.Fprintf(&b, ": %s", e.Detail)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
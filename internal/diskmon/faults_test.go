@@ -0,0 +1,275 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/history"
+)
+
+// faultConfig returns a config with distinct colors for the latched faults
+func faultConfig() *config.DiskMonitorConfig {
+	cfg := hotplugConfig()
+	cfg.ColorSmartFail = config.RGB{R: 255, G: 128, B: 0}
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 128}
+	cfg.LatchFaults = []string{"smart", "zpool"}
+	return cfg
+}
+
+// openHistory opens a fault history in a temporary directory
+func openHistory(t *testing.T) (*history.Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := history.Open(path)
+	if err != nil {
+		t.Fatalf("history.Open() error = %v", err)
+	}
+	return store, path
+}
+
+func TestMonitor_RestoreLatched(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	store, _ := openHistory(t)
+	since := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	if err := store.Record("WD-WCC7K1234567", history.Event{Time: since, Device: "sdc", Kind: "smart", Detail: "5 Reallocated_Sector_Ct"}, true); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	// Latched while the config still listed io
+	if err := store.Record("ZL2ABCDE", history.Event{Time: since, Device: "sdb", Kind: "io", Detail: "device offline"}, true); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	m := newTestMonitor(t, root, faultConfig())
+	m.history = store
+
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	// The failure latched before the restart shows right away, even though
+	// the disk now has another name
+	if state := m.disks["sda"]; state.serial != "WD-WCC7K1234567" || state.shown != displaySmartFail {
+		t.Errorf("sda serial = %q, shown = %v, want the latched SMART failure", state.serial, state.shown)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 128 0" {
+		t.Errorf("disk1 color = %q, want %q", color, "255 128 0")
+	}
+	if state := m.disks["sdb"]; state.shown != displayHealthy {
+		t.Errorf("sdb shown = %v, want %v", state.shown, displayHealthy)
+	}
+}
+
+func TestMonitor_RecordEvent(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	store, path := openHistory(t)
+	m := newTestMonitor(t, root, faultConfig())
+	m.history = store
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sdb"]
+
+	// A zpool fault stays latched after the pool recovers
+	m.update(state, func(s *diskState) { s.zpool = zpoolMember{pool: "tank", state: "FAULTED"} })
+	m.recordEvent(state, "zpool", "FAULTED in pool tank")
+	m.update(state, func(s *diskState) { s.zpool = zpoolMember{pool: "tank", state: "ONLINE"} })
+	m.recordEvent(state, "zpool-recovered", "ONLINE")
+	if state.shown != displayZpoolFault {
+		t.Errorf("sdb shown = %v after recovery, want the latched %v", state.shown, displayZpoolFault)
+	}
+
+	// and is restored by the next service
+	reopened, err := history.Open(path)
+	if err != nil {
+		t.Fatalf("history.Open() error = %v", err)
+	}
+	if latched := reopened.Latched("ZL2ABCDE"); len(latched) != 1 || latched[0].Kind != "zpool" {
+		t.Errorf("Latched() = %+v, want the zpool fault", latched)
+	}
+
+	reply, err := m.handleCommand([]string{"faults", "sdb"})
+	if err != nil {
+		t.Fatalf("handleCommand(faults) error = %v", err)
+	}
+	for _, want := range []string{"sdb (ZL2ABCDE):", "latched zpool since", " sdb zpool: FAULTED in pool tank", " sdb zpool-recovered: ONLINE"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("handleCommand(faults) = %q, want it to contain %q", reply, want)
+		}
+	}
+
+	reply, err = m.handleCommand([]string{"ack"})
+	if err != nil || reply != "acknowledged faults of sdb" {
+		t.Errorf("handleCommand(ack) = %q, %v, want %q", reply, err, "acknowledged faults of sdb")
+	}
+	if state.shown != displayHealthy {
+		t.Errorf("sdb shown = %v after acknowledging, want %v", state.shown, displayHealthy)
+	}
+	if color := readLED(t, root, "disk2", "color"); color != "255 255 255" {
+		t.Errorf("disk2 color = %q after acknowledging, want %q", color, "255 255 255")
+	}
+	if reply, _ := m.handleCommand([]string{"ack", "disk2"}); reply != "no disk has latched faults" {
+		t.Errorf("handleCommand(ack) = %q without faults", reply)
+	}
+	if acked := store.Acknowledged("ZL2ABCDE"); acked.IsZero() {
+		t.Error("Acknowledged() is zero after ack")
+	}
+}
+
+func TestMonitor_CheckDiskOnline_History(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	store, _ := openHistory(t)
+	m := newTestMonitor(t, root, faultConfig())
+	m.history = store
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	removeFakeDisk(t, root, "sdb")
+	m.checkDiskOnline()
+
+	events := store.Events("ZL2ABCDE")
+	if len(events) != 1 || events[0].Kind != "offline" || events[0].Device != "sdb" {
+		t.Errorf("Events() = %+v, want sdb going offline", events)
+	}
+	// Going offline is recorded but not latched
+	if latched := store.Latched("ZL2ABCDE"); len(latched) != 0 {
+		t.Errorf("Latched() = %+v, want none", latched)
+	}
+}
+
+func TestMonitor_FaultsDisabled(t *testing.T) {
+	m := newTestMonitor(t, t.TempDir(), faultConfig())
+	if _, err := m.handleCommand([]string{"faults"}); err == nil || err.Error() != "fault history is disabled" {
+		t.Errorf("handleCommand(faults) error = %v, want %q", err, "fault history is disabled")
+	}
+}
+
+func TestMonitor_LatchDim(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := faultConfig()
+	cfg.LatchFaults = []string{"offline"}
+	cfg.LatchPattern = "dim"
+	cfg.LatchedBrightness = 64
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	// Without a state file, the fault is latched until the service stops
+	removeFakeDisk(t, root, "sdb")
+	m.checkDiskOnline()
+	buildFakeDisks(t, root, testDisks())
+	m.checkDiskOnline()
+
+	state := m.disks["sdb"]
+	if state.shown != displayWasFaulted {
+		t.Fatalf("sdb shown = %v after coming back, want %v", state.shown, displayWasFaulted)
+	}
+	if color := readLED(t, root, "disk2", "color"); color != "255 0 0" {
+		t.Errorf("disk2 color = %q, want the dimmed offline color %q", color, "255 0 0")
+	}
+	if brightness := readLED(t, root, "disk2", "brightness"); brightness != "64" {
+		t.Errorf("disk2 brightness = %q, want %q", brightness, "64")
+	}
+
+	if reply, err := m.handleCommand([]string{"ack", "disk2"}); err != nil || reply != "acknowledged faults of sdb" {
+		t.Errorf("handleCommand(ack) = %q, %v, want %q", reply, err, "acknowledged faults of sdb")
+	}
+	if state.shown != displayHealthy {
+		t.Errorf("sdb shown = %v after acknowledging, want %v", state.shown, displayHealthy)
+	}
+	if brightness := readLED(t, root, "disk2", "brightness"); brightness != "255" {
+		t.Errorf("disk2 brightness = %q after acknowledging, want %q", brightness, "255")
+	}
+}
+
+func TestMonitor_AcknowledgeWarnings(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := faultConfig()
+	cfg.SmartDeltaThresholds = map[int]uint64{199: 10}
+	cfg.ColorSmartWarn = config.RGB{R: 255, G: 255, B: 0}
+	cfg.ColorIOErrorWarn = config.RGB{R: 255, G: 96, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	report := func(crcErrors int) command.Result {
+		return command.Result{Output: fmt.Sprintf(`{"smart_status": {"passed": true}, "ata_smart_attributes": {"table": [
+			{"id": 199, "name": "UDMA_CRC_Error_Count", "value": 200, "thresh": 0, "when_failed": "", "raw": {"value": %d}}]}}`, crcErrors)}
+	}
+	fake := &command.Fake{}
+	fake.Script("smartctl -j -a -n standby,0 /dev/sda", report(5), report(40))
+	m.commands = fake
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+
+	writeSCSICounters(t, root, "sda", "0x5", "0x100", "running")
+	m.checkIOErrors()
+	m.checkSMART(context.Background())
+	writeSCSICounters(t, root, "sda", "0x6", "0x200", "running")
+	m.checkIOErrors()
+	m.checkSMART(context.Background())
+	if state.shown != displaySmartWarning || state.ioWarning == "" {
+		t.Fatalf("sda shown = %v, I/O warning %q, want a SMART delta and an I/O warning", state.shown, state.ioWarning)
+	}
+
+	if reply, err := m.handleCommand([]string{"ack", "sda"}); err != nil || reply != "acknowledged faults of sda" {
+		t.Errorf("handleCommand(ack) = %q, %v, want %q", reply, err, "acknowledged faults of sda")
+	}
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v after acknowledging, want %v", state.shown, displayHealthy)
+	}
+
+	// The warnings stay cleared while nothing changes
+	m.checkIOErrors()
+	m.checkSMART(context.Background())
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v at the next checks, want %v", state.shown, displayHealthy)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 255 255" {
+		t.Errorf("disk1 color = %q after acknowledging, want %q", color, "255 255 255")
+	}
+}
+
+func TestMonitor_AcknowledgeBusy(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := faultConfig()
+	cfg.ColorIOErrorWarn = config.RGB{R: 255, G: 96, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+	writeSCSICounters(t, root, "sda", "0x5", "0x100", "running")
+	m.checkIOErrors()
+	writeSCSICounters(t, root, "sda", "0x6", "0x200", "running")
+	m.checkIOErrors()
+
+	// A SMART check of sda is running and may take until its timeout
+	state.ownIO.Lock()
+	expected := "busy with a SMART or standby check, acknowledge again: sda"
+	if reply, err := m.handleCommand([]string{"ack", "sda"}); err != nil || reply != expected {
+		t.Errorf("handleCommand(ack) = %q, %v, want %q", reply, err, expected)
+	}
+	if state.shown != displayIOWarning {
+		t.Errorf("sda shown = %v while busy, want %v", state.shown, displayIOWarning)
+	}
+	state.ownIO.Unlock()
+
+	if reply, err := m.handleCommand([]string{"ack", "sda"}); err != nil || reply != "acknowledged faults of sda" {
+		t.Errorf("handleCommand(ack) = %q, %v, want %q", reply, err, "acknowledged faults of sda")
+	}
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v after acknowledging, want %v", state.shown, displayHealthy)
+	}
+}
@@ -0,0 +1,167 @@
+package diskmon
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// hotplugSettle is how long to wait after the last block device event before
+// remapping, giving udev time to create the /dev/disk links
+const hotplugSettle = 2 * time.Second
+
+// uevent is a kernel object event received over netlink
+type uevent struct {
+	action  string
+	devPath string
+	env     map[string]string
+}
+
+// hotplugLoop remaps disks when block devices are added or removed. It
+// listens for kernel uevents and falls back to periodic rescans when the
+// netlink socket is unavailable.
+func (m *Monitor) hotplugLoop(ctx context.Context) {
+	events, err := listenUevents(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to listen for uevents, rescanning disks periodically: %v", err)
+		m.rescanLoop(ctx)
+		return
+	}
+
+	settle := m.clock().NewTimer(hotplugSettle)
+	settle.Stop()
+	defer settle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				log.Printf("Warning: uevent socket closed, rescanning disks periodically")
+				m.rescanLoop(ctx)
+				return
+			}
+			if !isDiskEvent(ev) {
+				continue
+			}
+			log.Printf("Block device %s: %s", ev.action, ev.env["DEVNAME"])
+			settle.Reset(hotplugSettle)
+		case <-settle.C():
+			m.handleHotplug(ctx)
+		}
+	}
+}
+
+func (m *Monitor) rescanLoop(ctx context.Context) {
+	interval := m.cfg.HotplugRescanInterval
+	if interval <= 0 {
+		interval = 30 // Default to 30 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.handleHotplug(ctx)
+		}
+	}
+}
+
+// handleHotplug remaps disks to slots and flags disks that disappeared
+func (m *Monitor) handleHotplug(ctx context.Context) {
+	if err := m.refreshDisks(); err != nil {
+		log.Printf("Warning: Failed to refresh disks: %v", err)
+		return
+	}
+
+	if m.cfg.CheckZpool {
+		if err := m.buildZpoolMapping(ctx); err != nil {
+			log.Printf("Warning: Failed to build zpool mapping: %v", err)
+		}
+	}
+
+	m.checkDiskOnline()
+}
+
+// listenUevents subscribes to kernel uevents. The returned channel is closed
+// when ctx is done or the socket fails.
+func listenUevents(ctx context.Context) (<-chan uevent, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// A non-blocking descriptor goes through the runtime poller, so closing
+	// the file unblocks a pending read
+	sock := os.NewFile(uintptr(fd), "uevent")
+	go func() {
+		<-ctx.Done()
+		sock.Close()
+	}()
+
+	events := make(chan uevent)
+	go func() {
+		defer close(events)
+		buf := make([]byte, 16*1024)
+		for {
+			n, err := sock.Read(buf)
+			if err != nil {
+				return
+			}
+			ev, ok := parseUevent(buf[:n])
+			if !ok {
+				continue
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// parseUevent parses a kernel uevent message of the form
+// "action@devpath\0KEY=value\0...". Messages rebroadcast by udev are ignored.
+func parseUevent(msg []byte) (uevent, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	header := string(fields[0])
+
+	at := bytes.IndexByte(fields[0], '@')
+	if at <= 0 {
+		return uevent{}, false
+	}
+
+	ev := uevent{
+		action:  header[:at],
+		devPath: header[at+1:],
+		env:     make(map[string]string),
+	}
+	for _, field := range fields[1:] {
+		if key, value, ok := bytes.Cut(field, []byte{'='}); ok {
+			ev.env[string(key)] = string(value)
+		}
+	}
+	return ev, true
+}
+
+// isDiskEvent reports whether ev is a whole disk being added or removed
+func isDiskEvent(ev uevent) bool {
+	if ev.env["SUBSYSTEM"] != "block" || ev.env["DEVTYPE"] != "disk" {
+		return false
+	}
+	return ev.action == "add" || ev.action == "remove"
+}
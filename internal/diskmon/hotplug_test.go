@@ -0,0 +1,204 @@
+package diskmon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// newTestMonitor returns a monitor rooted at a fake filesystem, with LEDs
+// disk1 through disk4
+func newTestMonitor(t *testing.T, root string, cfg *config.DiskMonitorConfig) *Monitor {
+	t.Helper()
+	for _, name := range []string{"disk1", "disk2", "disk3", "disk4"} {
+		if err := os.MkdirAll(filepath.Join(root, "sys", "class", "leds", name), 0755); err != nil {
+			t.Fatalf("Failed to create LED directory: %v", err)
+		}
+	}
+	return &Monitor{
+		cfg:         cfg,
+		root:        root,
+		disks:       make(map[string]*diskState),
+		ledToDevice: make(map[string]string),
+		deviceToLED: make(map[string]string),
+		zpoolLEDMap: make(map[string]string),
+		commands:    &command.Fake{},
+	}
+}
+
+func readLED(t *testing.T, root, name, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, "sys", "class", "leds", name, file))
+	if err != nil {
+		t.Fatalf("Failed to read %s/%s: %v", name, file, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func removeFakeDisk(t *testing.T, root, name string) {
+	t.Helper()
+	blockDir, err := os.Readlink(filepath.Join(root, "sys", "block", name))
+	if err != nil {
+		t.Fatalf("Failed to resolve %s: %v", name, err)
+	}
+	if err := os.RemoveAll(blockDir); err != nil {
+		t.Fatalf("Failed to remove %s: %v", blockDir, err)
+	}
+	for _, dir := range []string{"block", "class/block"} {
+		if err := os.Remove(filepath.Join(root, "sys", dir, name)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", name, err)
+		}
+	}
+}
+
+func hotplugConfig() *config.DiskMonitorConfig {
+	return &config.DiskMonitorConfig{
+		MappingMethod:      "hctl",
+		ColorDiskHealth:    config.RGB{R: 255, G: 255, B: 255},
+		ColorDiskUnavail:   config.RGB{R: 255, G: 0, B: 0},
+		BrightnessDiskLeds: 255,
+	}
+}
+
+func TestMonitor_Hotplug_OfflineRecovery(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, hotplugConfig())
+
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	// Pull sdb out of the second bay
+	removeFakeDisk(t, root, "sdb")
+	m.handleHotplug(context.Background())
+	if !m.disks["sdb"].offline {
+		t.Fatal("sdb should be offline after removal")
+	}
+	if color := readLED(t, root, "disk2", "color"); color != "255 0 0" {
+		t.Errorf("disk2 color = %q, want %q", color, "255 0 0")
+	}
+
+	// And put it back
+	buildFakeDisks(t, root, testDisks()[1:])
+	m.handleHotplug(context.Background())
+	if m.disks["sdb"].offline {
+		t.Error("sdb should be back online after re-insertion")
+	}
+	if color := readLED(t, root, "disk2", "color"); color != "255 255 255" {
+		t.Errorf("disk2 color = %q, want %q", color, "255 255 255")
+	}
+}
+
+func TestMonitor_Hotplug_NewDisk(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks()[:1])
+	m := newTestMonitor(t, root, hotplugConfig())
+
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	if trigger := readLED(t, root, "disk3", "trigger"); trigger != "none" {
+		t.Errorf("empty disk3 trigger = %q, want %q", trigger, "none")
+	}
+
+	buildFakeDisks(t, root, []fakeDisk{{name: "sdc", hctl: "2:0:0:0"}})
+	m.handleHotplug(context.Background())
+
+	if m.ledToDevice["disk3"] != "sdc" {
+		t.Errorf("ledToDevice[disk3] = %q, want %q", m.ledToDevice["disk3"], "sdc")
+	}
+	if trigger := readLED(t, root, "disk3", "trigger"); trigger != "oneshot" {
+		t.Errorf("disk3 trigger = %q, want %q", trigger, "oneshot")
+	}
+}
+
+func TestMonitor_Hotplug_NameReuse(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, hotplugConfig())
+
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	// sdb leaves bay 2 and the kernel hands its name to a disk in bay 4
+	removeFakeDisk(t, root, "sdb")
+	buildFakeDisks(t, root, []fakeDisk{{name: "sdb", hctl: "3:0:0:0"}})
+	m.handleHotplug(context.Background())
+
+	if m.ledToDevice["disk4"] != "sdb" || m.deviceToLED["sdb"] != "disk4" {
+		t.Errorf("sdb should be mapped to disk4, got ledToDevice = %v", m.ledToDevice)
+	}
+	if _, ok := m.ledToDevice["disk2"]; ok {
+		t.Errorf("disk2 should be unmapped, got %q", m.ledToDevice["disk2"])
+	}
+	if brightness := readLED(t, root, "disk2", "brightness"); brightness != "0" {
+		t.Errorf("disk2 brightness = %q, want %q", brightness, "0")
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	msg := []byte("add@/devices/pci0000:00/0000:00:17.0/ata3/host2/target2:0:0/2:0:0:0/block/sdc\x00" +
+		"ACTION=add\x00DEVPATH=/devices/pci0000:00/0000:00:17.0/ata3/host2/target2:0:0/2:0:0:0/block/sdc\x00" +
+		"SUBSYSTEM=block\x00MAJOR=8\x00MINOR=32\x00DEVNAME=sdc\x00DEVTYPE=disk\x00SEQNUM=4242\x00")
+
+	ev, ok := parseUevent(msg)
+	if !ok {
+		t.Fatal("parseUevent() ok = false, want true")
+	}
+	if ev.action != "add" || !strings.HasSuffix(ev.devPath, "/block/sdc") {
+		t.Errorf("parseUevent() action = %q, devPath = %q", ev.action, ev.devPath)
+	}
+	if ev.env["DEVNAME"] != "sdc" || ev.env["SUBSYSTEM"] != "block" {
+		t.Errorf("parseUevent() env = %v", ev.env)
+	}
+	if !isDiskEvent(ev) {
+		t.Error("isDiskEvent() = false, want true")
+	}
+
+	if _, ok := parseUevent([]byte("libudev\x00\xfe\xed\xca\xfe")); ok {
+		t.Error("parseUevent() should ignore udev messages")
+	}
+}
+
+func TestIsDiskEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		ev       uevent
+		expected bool
+	}{
+		{
+			name:     "disk removed",
+			ev:       uevent{action: "remove", env: map[string]string{"SUBSYSTEM": "block", "DEVTYPE": "disk"}},
+			expected: true,
+		},
+		{
+			name:     "partition added",
+			ev:       uevent{action: "add", env: map[string]string{"SUBSYSTEM": "block", "DEVTYPE": "partition"}},
+			expected: false,
+		},
+		{
+			name:     "disk changed",
+			ev:       uevent{action: "change", env: map[string]string{"SUBSYSTEM": "block", "DEVTYPE": "disk"}},
+			expected: false,
+		},
+		{
+			name:     "usb device",
+			ev:       uevent{action: "add", env: map[string]string{"SUBSYSTEM": "usb", "DEVTYPE": "usb_device"}},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isDiskEvent(tt.ev); result != tt.expected {
+				t.Errorf("isDiskEvent() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
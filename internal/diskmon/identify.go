@@ -0,0 +1,169 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/blockdev"
+)
+
+// parseTimeout parses an identify duration such as "90s" or "5m", or a plain
+// number of seconds
+func parseTimeout(s string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// identify blinks the bays of the disks matching target until the timeout
+// passes or the disks are cleared. A timeout of 0 uses IdentifyTimeout.
+func (m *Monitor) identify(target string, timeout time.Duration) ([]string, time.Time, error) {
+	if timeout <= 0 {
+		timeout = time.Duration(m.cfg.IdentifyTimeout) * time.Second
+		if timeout <= 0 {
+			timeout = 5 * time.Minute // Default to 5 minutes if invalid
+		}
+	}
+
+	states := m.resolveTarget(target)
+	if len(states) == 0 {
+		return nil, time.Time{}, fmt.Errorf("no mapped disk matches %q", target)
+	}
+
+	until := m.clock().Now().Add(timeout)
+	devices := make([]string, 0, len(states))
+	for _, state := range states {
+		m.update(state, func(s *diskState) {
+			s.identifyUntil = until
+		})
+		devices = append(devices, state.device)
+		log.Printf("Identifying disk /dev/%s for %s at %s", state.device, timeout, m.clock().Now().Format("2006-01-02 15:04:05"))
+	}
+	sort.Strings(devices)
+	return devices, until, nil
+}
+
+// clearIdentify ends identify mode on the disks matching target, or on all
+// disks if target is empty, and returns the disks it ended on
+func (m *Monitor) clearIdentify(target string) ([]string, error) {
+	states := m.snapshotDisks()
+	if target != "" {
+		if states = m.resolveTarget(target); len(states) == 0 {
+			return nil, fmt.Errorf("no mapped disk matches %q", target)
+		}
+	}
+
+	var devices []string
+	for _, state := range states {
+		if m.endIdentify(state, time.Time{}) {
+			devices = append(devices, state.device)
+			log.Printf("Stopped identifying disk /dev/%s at %s", state.device, m.clock().Now().Format("2006-01-02 15:04:05"))
+		}
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// endIdentify ends identify mode on a disk if it is due by now, or whenever
+// it is identifying if now is zero. It reports whether identify mode ended.
+func (m *Monitor) endIdentify(state *diskState, now time.Time) bool {
+	state.mu.RLock()
+	until := state.identifyUntil
+	state.mu.RUnlock()
+	if until.IsZero() || (!now.IsZero() && now.Before(until)) {
+		return false
+	}
+
+	m.update(state, func(s *diskState) {
+		s.identifyUntil = time.Time{}
+	})
+	return true
+}
+
+func (m *Monitor) identifyCheckLoop(ctx context.Context) {
+	ticker := m.clock().NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			m.expireIdentify(now)
+		}
+	}
+}
+
+// expireIdentify ends identify mode on disks whose timeout passed
+func (m *Monitor) expireIdentify(now time.Time) {
+	for _, state := range m.snapshotDisks() {
+		if m.endIdentify(state, now) {
+			log.Printf("Identify of disk /dev/%s timed out at %s", state.device, now.Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+// resolveTarget returns the mapped disks matching an identify target: a
+// slot such as disk3, a device name or path such as sda or
+// /dev/disk/by-id/..., a serial number, a WWN, or a vdev as zpool status
+// names it
+func (m *Monitor) resolveTarget(target string) []*diskState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Slots by their LED name
+	if state, ok := m.disks[m.ledToDevice[target]]; ok {
+		return []*diskState{state}
+	}
+
+	var states []*diskState
+	// Device names and /dev/disk links, which also covers most vdev names
+	for _, disk := range blockdev.PhysicalDisks(m.root, target) {
+		if state, ok := m.disks[disk]; ok {
+			states = append(states, state)
+		}
+	}
+	if len(states) > 0 {
+		return states
+	}
+
+	vdev := strings.TrimPrefix(target, "/dev/")
+	wwn := normalizeWWN(target)
+	for device, state := range m.disks {
+		state.mu.RLock()
+		zpoolDevice := state.zpool.device
+		state.mu.RUnlock()
+
+		switch {
+		case zpoolDevice != "" && (zpoolDevice == vdev || filepath.Base(zpoolDevice) == target):
+		case m.diskSerial(device) == target:
+		case strings.HasPrefix(wwn, "0x") && m.diskWWN(device) == wwn:
+		default:
+			continue
+		}
+		states = append(states, state)
+	}
+	if len(states) > 0 {
+		return states
+	}
+
+	// A vdev whose disk is gone goes by the name it had when the zpool
+	// mapping was built
+	if ledName, ok := m.zpoolLEDMap[target]; ok {
+		if state, ok := m.disks[m.ledToDevice[ledName]]; ok {
+			states = append(states, state)
+		}
+	}
+	return states
+}
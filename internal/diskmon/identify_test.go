@@ -0,0 +1,175 @@
+package diskmon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func identifyConfig() *config.DiskMonitorConfig {
+	cfg := hotplugConfig()
+	cfg.IdentifyTimeout = 60
+	cfg.IdentifyBlinkInterval = 100
+	cfg.ColorIdentify = config.RGB{R: 0, G: 255, B: 0}
+	return cfg
+}
+
+func TestMonitor_ResolveTarget(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, identifyConfig())
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	m.disks["sdb"].zpool = zpoolMember{pool: "tank", device: "disk/by-vdev/bay2"}
+	m.zpoolLEDMap["ata-ST4000VN008_ZDH0GONE"] = "disk2"
+
+	tests := []struct {
+		target   string
+		expected []string
+	}{
+		{target: "sda", expected: []string{"sda"}},
+		{target: "/dev/sdb", expected: []string{"sdb"}},
+		{target: "ata-WDC_WD40EFRX-68N32N0_WD-WCC7K1234567-part1", expected: []string{"sda"}},
+		{target: "/dev/disk/by-path/pci-0000:00:17.0-ata-2", expected: []string{"sdb"}},
+		{target: "WD-WCC7K1234567", expected: []string{"sda"}},
+		{target: "ZL2ABCDE", expected: []string{"sdb"}},
+		{target: "naa.5000C500A1B2C3D4", expected: []string{"sdb"}},
+		{target: "bay2", expected: []string{"sdb"}},
+		{target: "ata-ST4000VN008_ZDH0GONE", expected: []string{"sdb"}},
+		{target: "sdz", expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			var result []string
+			for _, state := range m.resolveTarget(tt.target) {
+				result = append(result, state.device)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("resolveTarget(%q) = %v, want %v", tt.target, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_Identify(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, identifyConfig())
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+	// Identify overrides faults and I/O activity
+	m.update(state, func(s *diskState) { s.offline = true })
+
+	devices, until, err := m.identify("WD-WCC7K1234567", 0)
+	if err != nil {
+		t.Fatalf("identify() error = %v", err)
+	}
+	if !reflect.DeepEqual(devices, []string{"sda"}) {
+		t.Errorf("identify() = %v, want [sda]", devices)
+	}
+	if timeout := time.Until(until); timeout <= 55*time.Second || timeout > time.Minute {
+		t.Errorf("identify() until = %v, want IdentifyTimeout from now", until)
+	}
+	for attr, value := range map[string]string{"color": "0 255 0", "trigger": "timer", "delay_on": "100"} {
+		if result := readLED(t, root, "disk1", attr); result != value {
+			t.Errorf("identifying disk1 %s = %q, want %q", attr, result, value)
+		}
+	}
+
+	// The timeout ends identify mode
+	m.expireIdentify(until.Add(-time.Second))
+	if state.shown != displayIdentify {
+		t.Errorf("sda shown = %v before the timeout, want %v", state.shown, displayIdentify)
+	}
+	m.expireIdentify(until)
+	if state.shown != displayOffline {
+		t.Errorf("sda shown = %v after the timeout, want %v", state.shown, displayOffline)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 0 0" {
+		t.Errorf("disk1 color = %q after the timeout, want %q", color, "255 0 0")
+	}
+
+	if _, _, err := m.identify("sdz", 0); err == nil {
+		t.Error("identify() of an unknown disk should fail")
+	}
+}
+
+func TestMonitor_HandleCommand(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, identifyConfig())
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	steps := []struct {
+		args  []string
+		reply string
+		err   string
+		shown map[string]displayState
+	}{
+		{args: []string{"identify", "sda", "90"}, reply: "identifying sda until ", shown: map[string]displayState{"sda": displayIdentify, "sdb": displayHealthy}},
+		{args: []string{"identify", "ZL2ABCDE", "10m"}, reply: "identifying sdb until ", shown: map[string]displayState{"sda": displayIdentify, "sdb": displayIdentify}},
+		{args: []string{"clear", "sdb"}, reply: "cleared sdb", shown: map[string]displayState{"sda": displayIdentify, "sdb": displayHealthy}},
+		{args: []string{"identify", "sdb"}, reply: "identifying sdb until "},
+		{args: []string{"clear"}, reply: "cleared sda sdb", shown: map[string]displayState{"sda": displayHealthy, "sdb": displayHealthy}},
+		{args: []string{"clear"}, reply: "no disk is being identified"},
+		{args: []string{"identify", "sda", "soon"}, err: `invalid duration "soon"`},
+		{args: []string{"identify"}, err: "usage: identify <device|serial|wwn|vdev> [duration]"},
+		{args: []string{"clear", "sdz"}, err: `no mapped disk matches "sdz"`},
+		{args: []string{"blink", "sda"}, err: `unknown command "blink"`},
+	}
+	for _, step := range steps {
+		reply, err := m.handleCommand(step.args)
+		switch {
+		case step.err != "":
+			if err == nil || err.Error() != step.err {
+				t.Errorf("handleCommand(%v) error = %v, want %q", step.args, err, step.err)
+			}
+		case err != nil:
+			t.Errorf("handleCommand(%v) error = %v", step.args, err)
+		case !strings.HasPrefix(reply, step.reply):
+			t.Errorf("handleCommand(%v) = %q, want %q...", step.args, reply, step.reply)
+		}
+		for device, shown := range step.shown {
+			if state := m.disks[device]; state.shown != shown {
+				t.Errorf("handleCommand(%v): %s shown = %v, want %v", step.args, device, state.shown, shown)
+			}
+		}
+	}
+}
+
+func TestMonitor_IdentifyCheckLoop(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, identifyConfig())
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+
+	clk := runLoop(t, m, (*Monitor).identifyCheckLoop)
+	if _, _, err := m.identify("sda", 0); err != nil {
+		t.Fatalf("identify() error = %v", err)
+	}
+	waitShown(t, state, displayIdentify)
+
+	// Identify mode lasts for IdentifyTimeout of the monitor's clock
+	clk.Advance(59 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	state.mu.RLock()
+	shown := state.shown
+	state.mu.RUnlock()
+	if shown != displayIdentify {
+		t.Fatalf("sda shown = %v before the timeout, want %v", shown, displayIdentify)
+	}
+
+	clk.Advance(time.Second)
+	waitShown(t, state, displayHealthy)
+}
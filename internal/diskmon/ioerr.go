@@ -0,0 +1,230 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scsiCounters holds the error counters and state of a SCSI disk from
+// /sys/block/<dev>/device
+type scsiCounters struct {
+	ioerr  uint64 // commands that completed with an error
+	iodone uint64 // commands that completed
+	state  string // "running", "offline", "blocked", ...
+}
+
+func (m *Monitor) ioErrorCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckIOErrorsInterval
+	if interval <= 0 {
+		interval = 5 // Default to 5 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkIOErrors()
+		}
+	}
+}
+
+func (m *Monitor) checkIOErrors() {
+	for _, state := range m.snapshotDisks() {
+		// A command of the service's own is in flight, the errors it
+		// causes are not counted yet
+		if !state.ownIO.TryLock() {
+			continue
+		}
+		m.checkDiskIOErrors(state)
+		state.ownIO.Unlock()
+	}
+}
+
+// checkDiskIOErrors compares the error counter of a disk with its baseline.
+// The caller must hold state.ownIO.
+func (m *Monitor) checkDiskIOErrors(state *diskState) {
+	state.mu.RLock()
+	device := state.device
+	offline := state.offline
+	base := state.ioErrBase
+	baseSet := state.ioErrBaseSet
+	seen := state.ioErrSeen
+	since := state.ioErrSince
+	wasWarning := state.ioWarning
+	wasFault := state.ioFault
+	state.mu.RUnlock()
+
+	if offline {
+		return
+	}
+	counters, ok := m.readSCSICounters(device)
+	if !ok {
+		// NVMe and virtual disks have no SCSI counters
+		return
+	}
+	now := m.clock().Now()
+	if !baseSet || counters.ioerr < base {
+		// Errors from before the service started, or from a disk that
+		// was replaced, do not count
+		base = counters.ioerr
+	}
+	if counters.ioerr != seen {
+		since = now
+	}
+	quiet := time.Duration(m.cfg.IOErrorQuietPeriod) * time.Second
+	if quiet > 0 && counters.ioerr > base && now.Sub(since) >= quiet {
+		// Errors followed by a quiet period were transient
+		base = counters.ioerr
+	}
+
+	warning, fault := m.ioErrorReasons(counters, counters.ioerr-base)
+	m.update(state, func(s *diskState) {
+		s.ioErrBase = base
+		s.ioErrBaseSet = true
+		s.ioErrSeen = counters.ioerr
+		s.ioErrSince = since
+		s.ioWarning = warning
+		s.ioFault = fault
+	})
+
+	timestamp := now.Format("2006-01-02 15:04:05")
+	switch {
+	case fault != "" && fault != wasFault:
+		log.Printf("Disk /dev/%s I/O failure (%s) at %s", device, fault, timestamp)
+		m.recordEvent(state, "io", fault)
+	case warning != "" && warning != wasWarning:
+		log.Printf("Disk /dev/%s I/O warning (%s) at %s", device, warning, timestamp)
+	case fault == "" && warning == "" && (wasFault != "" || wasWarning != ""):
+		log.Printf("Disk /dev/%s I/O errors cleared at %s", device, timestamp)
+	}
+}
+
+// forgetIOErrors moves the I/O error baseline of a disk to its current
+// error count. It reports whether the disk had new errors, or that it is
+// busy rather than waiting for a command of the service's own, which can
+// take as long as the smartctl timeout.
+func (m *Monitor) forgetIOErrors(state *diskState) (forgot, busy bool) {
+	if !state.ownIO.TryLock() {
+		return false, true
+	}
+	defer state.ownIO.Unlock()
+
+	state.mu.RLock()
+	device := state.device
+	base := state.ioErrBase
+	baseSet := state.ioErrBaseSet
+	state.mu.RUnlock()
+
+	counters, ok := m.readSCSICounters(device)
+	if !ok || !baseSet || counters.ioerr <= base {
+		return false, false
+	}
+	warning, fault := m.ioErrorReasons(counters, 0)
+	m.update(state, func(s *diskState) {
+		s.ioErrBase = counters.ioerr
+		s.ioErrSeen = counters.ioerr
+		s.ioWarning = warning
+		s.ioFault = fault
+	})
+	return true, false
+}
+
+// ownCommand runs a command the service sends to a disk itself, such as
+// CHECK POWER MODE or smartctl, and leaves the errors it causes out of the
+// I/O error count. Pass-through commands that return the ATA registers
+// complete with CHECK CONDITION, which the kernel counts in ioerr_cnt.
+func (m *Monitor) ownCommand(device string, run func()) {
+	m.mu.RLock()
+	state := m.disks[device]
+	m.mu.RUnlock()
+	if state == nil || !m.cfg.CheckIOErrors {
+		run()
+		return
+	}
+
+	state.ownIO.Lock()
+	defer state.ownIO.Unlock()
+
+	before, ok := m.readSCSICounters(device)
+	run()
+	if !ok {
+		return
+	}
+	after, ok := m.readSCSICounters(device)
+	if !ok || after.ioerr <= before.ioerr {
+		return
+	}
+
+	caused := after.ioerr - before.ioerr
+	state.mu.Lock()
+	if state.ioErrBaseSet {
+		state.ioErrBase += caused
+		state.ioErrSeen += caused
+	}
+	state.mu.Unlock()
+}
+
+// ioErrorReasons returns why a disk shows an I/O warning or failure, given
+// its SCSI counters and the errors since monitoring started
+func (m *Monitor) ioErrorReasons(c scsiCounters, newErrors uint64) (warning, fault string) {
+	switch c.state {
+	case "", "running":
+	case "blocked", "quiesce":
+		// The SCSI layer is recovering or the system is suspending
+		warning = "device " + c.state
+	default:
+		fault = "device " + c.state
+	}
+
+	if newErrors > 0 {
+		reason := fmt.Sprintf("%d I/O errors in %d commands", newErrors, c.iodone)
+		threshold := uint64(m.cfg.IOErrorFailThreshold)
+		if threshold > 0 && newErrors >= threshold {
+			fault = joinReasons(fault, reason)
+		} else {
+			warning = joinReasons(warning, reason)
+		}
+	}
+	return warning, fault
+}
+
+// joinReasons appends a reason to a list of reasons
+func joinReasons(reasons, reason string) string {
+	if reasons == "" {
+		return reason
+	}
+	return reasons + ", " + reason
+}
+
+// readSCSICounters reads the error counters and state of a SCSI disk. It
+// reports false if the disk has no error counter.
+func (m *Monitor) readSCSICounters(device string) (scsiCounters, bool) {
+	read := func(name string) (string, bool) {
+		data, err := os.ReadFile(m.sysPath("block", device, "device", name))
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(string(data)), true
+	}
+
+	ioerr, ok := read("ioerr_cnt")
+	if !ok {
+		return scsiCounters{}, false
+	}
+	var c scsiCounters
+	// The counters are printed in hex, as 0x1a
+	c.ioerr, _ = strconv.ParseUint(ioerr, 0, 64)
+	if iodone, ok := read("iodone_cnt"); ok {
+		c.iodone, _ = strconv.ParseUint(iodone, 0, 64)
+	}
+	c.state, _ = read("state")
+	return c, true
+}
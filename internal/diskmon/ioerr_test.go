@@ -0,0 +1,207 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/clock"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// writeSCSICounters writes the error counters and state of a fake disk
+func writeSCSICounters(t *testing.T, root, device, ioerr, iodone, state string) {
+	t.Helper()
+	deviceDir := filepath.Join(root, "sys", "block", device, "device")
+	writeFile(t, filepath.Join(deviceDir, "ioerr_cnt"), ioerr+"\n")
+	writeFile(t, filepath.Join(deviceDir, "iodone_cnt"), iodone+"\n")
+	writeFile(t, filepath.Join(deviceDir, "state"), state+"\n")
+}
+
+func TestMonitor_ReadSCSICounters(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	writeSCSICounters(t, root, "sda", "0x1a", "0x2f4c1", "blocked")
+	m := &Monitor{root: root}
+
+	counters, ok := m.readSCSICounters("sda")
+	if !ok {
+		t.Fatal("readSCSICounters() found no counters")
+	}
+	expected := scsiCounters{ioerr: 26, iodone: 193729, state: "blocked"}
+	if counters != expected {
+		t.Errorf("readSCSICounters() = %+v, want %+v", counters, expected)
+	}
+
+	if _, ok := m.readSCSICounters("sdb"); ok {
+		t.Error("readSCSICounters() of a disk without counters should report false")
+	}
+}
+
+func TestMonitor_IOErrorReasons(t *testing.T) {
+	m := &Monitor{cfg: &config.DiskMonitorConfig{IOErrorFailThreshold: 10}}
+	tests := []struct {
+		name      string
+		counters  scsiCounters
+		newErrors uint64
+		warning   string
+		fault     string
+	}{
+		{name: "healthy", counters: scsiCounters{iodone: 100, state: "running"}},
+		{name: "new errors", counters: scsiCounters{iodone: 100, state: "running"}, newErrors: 3, warning: "3 I/O errors in 100 commands"},
+		{name: "error threshold", counters: scsiCounters{iodone: 100, state: "running"}, newErrors: 10, fault: "10 I/O errors in 100 commands"},
+		{name: "blocked", counters: scsiCounters{state: "blocked"}, warning: "device blocked"},
+		{name: "offline with errors", counters: scsiCounters{iodone: 5, state: "offline"}, newErrors: 1, warning: "1 I/O errors in 5 commands", fault: "device offline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, fault := m.ioErrorReasons(tt.counters, tt.newErrors)
+			if warning != tt.warning || fault != tt.fault {
+				t.Errorf("ioErrorReasons() = %q, %q, want %q, %q", warning, fault, tt.warning, tt.fault)
+			}
+		})
+	}
+}
+
+func TestMonitor_CheckIOErrors(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.IOErrorFailThreshold = 10
+	cfg.ColorIOErrorWarn = config.RGB{R: 255, G: 96, B: 0}
+	cfg.ColorIOErrorFail = config.RGB{R: 255, G: 0, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	// Errors from before the service started are ignored
+	writeSCSICounters(t, root, "sda", "0x5", "0x100", "running")
+	m.checkIOErrors()
+	state := m.disks["sda"]
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v with old errors, want %v", state.shown, displayHealthy)
+	}
+
+	steps := []struct {
+		name  string
+		ioerr string
+		state string
+		shown displayState
+		color string
+	}{
+		{name: "new error", ioerr: "0x6", state: "running", shown: displayIOWarning, color: "255 96 0"},
+		{name: "threshold", ioerr: "0xf", state: "running", shown: displayIOFault, color: "255 0 0"},
+		{name: "device offline", ioerr: "0x6", state: "offline", shown: displayIOFault, color: "255 0 0"},
+		// Without a quiet period, new errors stay until the service restarts
+		{name: "running again", ioerr: "0x6", state: "running", shown: displayIOWarning, color: "255 96 0"},
+	}
+	for _, step := range steps {
+		writeSCSICounters(t, root, "sda", step.ioerr, "0x200", step.state)
+		m.checkIOErrors()
+		if state.shown != step.shown {
+			t.Errorf("%s: sda shown = %v, want %v", step.name, state.shown, step.shown)
+		}
+		if color := readLED(t, root, "disk1", "color"); color != step.color {
+			t.Errorf("%s: disk1 color = %q, want %q", step.name, color, step.color)
+		}
+	}
+}
+
+func TestMonitor_CheckIOErrors_OwnCommands(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.CheckStandby = true
+	cfg.StandbyMonPath = "/usr/bin/ugreen-check-standby"
+	cfg.CheckIOErrors = true
+	cfg.IOErrorFailThreshold = 3
+	cfg.ColorIOErrorWarn = config.RGB{R: 255, G: 96, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	ioerr := 0
+	setErrors := func(n int) {
+		ioerr = n
+		writeSCSICounters(t, root, "sda", fmt.Sprintf("%#x", n), "0x100", "running")
+	}
+	// Pass-through commands return the ATA registers with CHECK CONDITION,
+	// which the kernel counts as an I/O error
+	passthrough := func() { setErrors(ioerr + 1) }
+	fake := &command.Fake{}
+	fake.Script("/usr/bin/ugreen-check-standby /dev/sda", command.Result{Output: " drive state is:  active/idle", Run: passthrough})
+	fake.Script("smartctl -j -a -n standby,0 /dev/sda", command.Result{Output: `{"smart_status": {"passed": true}}`, Run: passthrough})
+	m.commands = fake
+	setErrors(0)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkIOErrors()
+	for i := 0; i < 5; i++ {
+		m.checkStandby(context.Background())
+		m.checkSMART(context.Background())
+		m.checkIOErrors()
+	}
+	state := m.disks["sda"]
+	if ioerr != 10 {
+		t.Fatalf("ioerr_cnt = %d, want the 10 errors of the service's commands", ioerr)
+	}
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v after the service's own commands, want %v", state.shown, displayHealthy)
+	}
+
+	// Errors from other commands still count
+	setErrors(ioerr + 1)
+	m.checkStandby(context.Background())
+	m.checkIOErrors()
+	if state.shown != displayIOWarning {
+		t.Errorf("sda shown = %v after an I/O error, want %v", state.shown, displayIOWarning)
+	}
+}
+
+func TestMonitor_CheckIOErrors_QuietPeriod(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.IOErrorFailThreshold = 10
+	cfg.IOErrorQuietPeriod = 3600
+	cfg.ColorIOErrorWarn = config.RGB{R: 255, G: 96, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	m.clk = clk
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+
+	writeSCSICounters(t, root, "sda", "0x5", "0x100", "running")
+	m.checkIOErrors()
+	writeSCSICounters(t, root, "sda", "0x6", "0x200", "running")
+	m.checkIOErrors()
+	if state.shown != displayIOWarning {
+		t.Fatalf("sda shown = %v after an I/O error, want %v", state.shown, displayIOWarning)
+	}
+
+	clk.Advance(3599 * time.Second)
+	m.checkIOErrors()
+	if state.shown != displayIOWarning {
+		t.Errorf("sda shown = %v before the quiet period passed, want %v", state.shown, displayIOWarning)
+	}
+
+	clk.Advance(time.Second)
+	m.checkIOErrors()
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v after the quiet period, want %v", state.shown, displayHealthy)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 255 255" {
+		t.Errorf("disk1 color = %q after the quiet period, want %q", color, "255 255 255")
+	}
+
+	writeSCSICounters(t, root, "sda", "0x7", "0x300", "running")
+	m.checkIOErrors()
+	if state.shown != displayIOWarning {
+		t.Errorf("sda shown = %v after another I/O error, want %v", state.shown, displayIOWarning)
+	}
+}
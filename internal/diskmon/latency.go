@@ -0,0 +1,125 @@
+package diskmon
+
+import (
+	"log"
+	"slices"
+	"time"
+)
+
+// latencyWeight is the weight of a new sample in a disk's average latency
+const latencyWeight = 0.1
+
+// trackLatency updates the average request latency and the stall timer of a
+// disk from its diskstats counters
+func (m *Monitor) trackLatency(state *diskState, cur ioCounters, now time.Time) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	prev := state.latencyIO
+	state.latencyIO = cur
+	if prev == (ioCounters{}) {
+		return
+	}
+
+	ops := counterDelta(prev.reads, cur.reads) + counterDelta(prev.writes, cur.writes)
+	if ops > 0 {
+		ticks := counterDelta(prev.readTicks, cur.readTicks) + counterDelta(prev.writeTicks, cur.writeTicks)
+		sample := float64(ticks) / float64(ops)
+		if state.latency == 0 {
+			state.latency = sample
+		} else {
+			state.latency += latencyWeight * (sample - state.latency)
+		}
+	}
+
+	completions := ops + counterDelta(prev.discards, cur.discards) + counterDelta(prev.flushes, cur.flushes)
+	switch {
+	case cur.inFlight == 0 || completions > 0:
+		state.stallSince = time.Time{}
+	case state.stallSince.IsZero():
+		state.stallSince = now
+	}
+}
+
+// checkLatency flags disks whose I/O stalled or whose average latency is far
+// above the median of the other disks. The caller must hold m.mu.
+func (m *Monitor) checkLatency(now time.Time) {
+	stallTimeout := m.cfg.StallTimeout
+	if stallTimeout <= 0 {
+		stallTimeout = 10 // Default to 10 seconds if invalid
+	}
+	factor := m.cfg.LatencyFactor
+	if factor <= 0 {
+		factor = 5 // Default to 5 times the median if invalid
+	}
+
+	m.latencies = m.latencies[:0]
+	for _, state := range m.disks {
+		state.mu.RLock()
+		if state.latency > 0 && !state.offline {
+			m.latencies = append(m.latencies, state.latency)
+		}
+		state.mu.RUnlock()
+	}
+	slices.Sort(m.latencies)
+
+	for _, state := range m.disks {
+		state.mu.RLock()
+		device := state.device
+		offline := state.offline
+		latency := state.latency
+		stallSince := state.stallSince
+		wasSlow := state.slow
+		state.mu.RUnlock()
+
+		var slow string
+		var median float64
+		switch {
+		case offline:
+		case !stallSince.IsZero() && now.Sub(stallSince) >= time.Duration(stallTimeout)*time.Second:
+			slow = "I/O stalled"
+		case latency > 0:
+			median = peerMedian(m.latencies, latency)
+			if median > 0 && latency > median*factor && latency >= float64(m.cfg.LatencyMin) {
+				slow = "high latency"
+			}
+		}
+		if slow == wasSlow {
+			continue
+		}
+
+		m.update(state, func(s *diskState) {
+			s.slow = slow
+		})
+
+		timestamp := now.Format("2006-01-02 15:04:05")
+		switch slow {
+		case "I/O stalled":
+			log.Printf("Disk /dev/%s has had I/O in flight without completions since %s at %s", device, stallSince.Format("15:04:05"), timestamp)
+		case "high latency":
+			log.Printf("Disk /dev/%s is slow: %.1fms per request, other disks %.1fms at %s", device, latency, median, timestamp)
+		default:
+			log.Printf("Disk /dev/%s is no longer slow at %s", device, timestamp)
+		}
+	}
+}
+
+// peerMedian returns the median of the sorted latencies without one
+// occurrence of own, or 0 if fewer than two other disks have a latency
+func peerMedian(sorted []float64, own float64) float64 {
+	if len(sorted) < 3 {
+		return 0
+	}
+	skip, _ := slices.BinarySearch(sorted, own)
+	at := func(i int) float64 {
+		if i >= skip {
+			i++
+		}
+		return sorted[i]
+	}
+	n := len(sorted) - 1
+	if n%2 == 1 {
+		return at(n / 2)
+	}
+	return (at(n/2-1) + at(n/2)) / 2
+}
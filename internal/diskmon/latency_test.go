@@ -0,0 +1,147 @@
+package diskmon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func TestPeerMedian(t *testing.T) {
+	tests := []struct {
+		name     string
+		sorted   []float64
+		own      float64
+		expected float64
+	}{
+		{name: "too few peers", sorted: []float64{5, 80}, own: 80, expected: 0},
+		{name: "two peers", sorted: []float64{4, 6, 80}, own: 80, expected: 5},
+		{name: "three peers", sorted: []float64{4, 5, 9, 80}, own: 80, expected: 5},
+		{name: "own in the middle", sorted: []float64{4, 5, 9, 80}, own: 5, expected: 9},
+		{name: "equal latencies", sorted: []float64{5, 5, 5}, own: 5, expected: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := peerMedian(tt.sorted, tt.own); result != tt.expected {
+				t.Errorf("peerMedian() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_TrackLatency(t *testing.T) {
+	m := &Monitor{cfg: &config.DiskMonitorConfig{}}
+	state := &diskState{device: "sda"}
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	// The first sample is only a baseline
+	m.trackLatency(state, ioCounters{reads: 10, readTicks: 100, inFlight: 1}, start)
+	if state.latency != 0 || !state.stallSince.IsZero() {
+		t.Errorf("latency = %v, stallSince = %v after the first sample", state.latency, state.stallSince)
+	}
+
+	// 4 requests in 40 ms
+	m.trackLatency(state, ioCounters{reads: 12, readTicks: 120, writes: 2, writeTicks: 20, inFlight: 1}, start.Add(time.Second))
+	if state.latency != 10 {
+		t.Errorf("latency = %v, want %v", state.latency, 10.0)
+	}
+
+	// Requests in flight without completions start the stall timer
+	stalled := ioCounters{reads: 12, readTicks: 120, writes: 2, writeTicks: 20, inFlight: 3}
+	m.trackLatency(state, stalled, start.Add(2*time.Second))
+	m.trackLatency(state, stalled, start.Add(3*time.Second))
+	if !state.stallSince.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("stallSince = %v, want %v", state.stallSince, start.Add(2*time.Second))
+	}
+
+	// A completion resets it and moves the average towards the new latency
+	m.trackLatency(state, ioCounters{reads: 13, readTicks: 230, writes: 2, writeTicks: 20, inFlight: 2}, start.Add(4*time.Second))
+	if !state.stallSince.IsZero() {
+		t.Errorf("stallSince = %v after a completion, want zero", state.stallSince)
+	}
+	if state.latency != 20 {
+		t.Errorf("latency = %v, want %v", state.latency, 20.0)
+	}
+}
+
+func TestMonitor_CheckLatency(t *testing.T) {
+	root := t.TempDir()
+	disks := []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0"},
+		{name: "sdc", hctl: "2:0:0:0"},
+		{name: "sdd", hctl: "3:0:0:0"},
+	}
+	buildFakeDisks(t, root, disks)
+	cfg := hotplugConfig()
+	cfg.CheckLatency = true
+	cfg.LatencyFactor = 5
+	cfg.LatencyMin = 50
+	cfg.StallTimeout = 10
+	cfg.ColorDiskSlow = config.RGB{R: 255, G: 0, B: 128}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	// writeStats gives each disk the same number of requests with the given
+	// total time and requests in flight
+	writeStats := func(ops int, ticks, inFlight map[string]int) {
+		var content strings.Builder
+		for i, d := range disks {
+			fmt.Fprintf(&content, "   8 %7d %s %d 0 0 %d 0 0 0 0 %d 0 0\n", 16*i, d.name, ops, ticks[d.name], inFlight[d.name])
+		}
+		writeFile(t, filepath.Join(root, "proc", "diskstats"), content.String())
+	}
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sample := func(at time.Time) {
+		data, err := os.ReadFile(filepath.Join(root, "proc", "diskstats"))
+		if err != nil {
+			t.Fatalf("Failed to read diskstats: %v", err)
+		}
+		forEachDiskstat(data, func(name []byte, c ioCounters) {
+			m.trackLatency(m.disks[string(name)], c, at)
+		})
+		m.checkLatency(at)
+	}
+
+	writeStats(10, map[string]int{}, nil)
+	sample(start)
+	// sdd takes 80 ms per request, the others 5 to 8 ms
+	writeStats(20, map[string]int{"sda": 50, "sdb": 60, "sdc": 80, "sdd": 800}, nil)
+	sample(start.Add(time.Second))
+
+	for device, want := range map[string]displayState{"sda": displayHealthy, "sdb": displayHealthy, "sdc": displayHealthy, "sdd": displaySlow} {
+		if state := m.disks[device]; state.shown != want {
+			t.Errorf("%s shown = %v, want %v", device, state.shown, want)
+		}
+	}
+	if color := readLED(t, root, m.deviceToLED["sdd"], "color"); color != "255 0 128" {
+		t.Errorf("sdd color = %q, want %q", color, "255 0 128")
+	}
+
+	// sda has I/O in flight that never completes
+	ticks := map[string]int{"sda": 50, "sdb": 60, "sdc": 80, "sdd": 800}
+	writeStats(20, ticks, map[string]int{"sda": 1})
+	sample(start.Add(2 * time.Second))
+	sample(start.Add(5 * time.Second))
+	if m.disks["sda"].slow != "" {
+		t.Errorf("sda slow = %q before the stall timeout", m.disks["sda"].slow)
+	}
+	sample(start.Add(12 * time.Second))
+	if state := m.disks["sda"]; state.slow != "I/O stalled" || state.shown != displaySlow {
+		t.Errorf("sda slow = %q, shown = %v, want stalled", state.slow, state.shown)
+	}
+
+	// Once the request completes the disk recovers
+	ticks["sda"] = 60
+	writeStats(21, ticks, nil)
+	sample(start.Add(13 * time.Second))
+	if state := m.disks["sda"]; state.slow != "" || state.shown != displayHealthy {
+		t.Errorf("sda slow = %q, shown = %v after the stall, want healthy", state.slow, state.shown)
+	}
+}
@@ -0,0 +1,247 @@
+package diskmon
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/led"
+)
+
+var ataRegex = regexp.MustCompile(`ata\d+`)
+
+// ataPortRegex matches the ATA port in the sysfs path of a disk
+var ataPortRegex = regexp.MustCompile(`/ata\d+/`)
+
+// sysPath returns a path below /sys, relative to the monitor's filesystem root
+func (m *Monitor) sysPath(elem ...string) string {
+	return filepath.Join(append([]string{"/", m.root, "sys"}, elem...)...)
+}
+
+// devPath returns a path below /dev, relative to the monitor's filesystem root
+func (m *Monitor) devPath(elem ...string) string {
+	return filepath.Join(append([]string{"/", m.root, "dev"}, elem...)...)
+}
+
+// procPath returns a path below /proc, relative to the monitor's filesystem
+// root
+func (m *Monitor) procPath(elem ...string) string {
+	return filepath.Join(append([]string{"/", m.root, "proc"}, elem...)...)
+}
+
+func (m *Monitor) newLED(name string) *led.LED {
+	return led.NewLEDAt(m.sysPath("class/leds"), name)
+}
+
+// enumerateDisks returns a map from mapping key (ata port, HCTL, NVMe
+// controller or PCI address, serial, WWN or /dev/disk link name) to the
+// whole-disk block device name
+func (m *Monitor) enumerateDisks() (map[string]string, error) {
+	devMap := make(map[string]string)
+
+	switch m.cfg.MappingMethod {
+	case "by-id", "by-path":
+		return m.diskLinks(m.cfg.MappingMethod, ""), nil
+	case "wwn":
+		// udev links are preferred, the sysfs wwid covers systems without udev
+		for wwn, device := range m.diskLinks("by-id", "wwn-") {
+			if m.ataDisk(device) {
+				devMap[wwn] = device
+			}
+		}
+	}
+
+	devices, err := m.blockDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, device := range devices {
+		switch m.cfg.MappingMethod {
+		case "hctl", "serial", "wwn":
+			// The bays are SATA ports, a USB disk must not take over the
+			// LED of the bay its address or serial matches
+			if !m.ataDisk(device) {
+				continue
+			}
+		}
+
+		var key string
+		switch m.cfg.MappingMethod {
+		case "ata":
+			linkTarget, err := os.Readlink(m.sysPath("block", device))
+			if err != nil {
+				continue
+			}
+			key = ataRegex.FindString(linkTarget)
+		case "hctl":
+			key = m.diskHCTL(device)
+		case "nvme":
+			ctrl, pciAddr := m.nvmeController(device)
+			if ctrl == "" {
+				continue
+			}
+			// Only the first namespace of each controller gets an LED
+			if _, ok := devMap[ctrl]; ok {
+				continue
+			}
+			devMap[pciAddr] = device
+			key = ctrl
+		case "serial":
+			key = m.diskSerial(device)
+		case "wwn":
+			key = m.diskWWN(device)
+			if _, ok := devMap[key]; ok {
+				continue
+			}
+		}
+		if key != "" {
+			devMap[key] = device
+		}
+	}
+
+	return devMap, nil
+}
+
+// blockDevices lists the block devices in /sys/block
+func (m *Monitor) blockDevices() ([]string, error) {
+	entries, err := os.ReadDir(m.sysPath("block"))
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		devices = append(devices, entry.Name())
+	}
+	return devices, nil
+}
+
+// diskLinks resolves the symlinks in /dev/disk/<dir> whose names start with
+// prefix, keyed by the link name without the prefix. Links to partitions are
+// skipped so that only whole disks are returned.
+func (m *Monitor) diskLinks(dir, prefix string) map[string]string {
+	links := make(map[string]string)
+
+	entries, err := os.ReadDir(m.devPath("disk", dir))
+	if err != nil {
+		return links
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		target, err := os.Readlink(m.devPath("disk", dir, name))
+		if err != nil {
+			continue
+		}
+
+		device := filepath.Base(target)
+		if _, err := os.Stat(m.sysPath("block", device)); err != nil {
+			// Partitions only appear below their parent disk
+			continue
+		}
+		links[strings.TrimPrefix(name, prefix)] = device
+	}
+
+	return links
+}
+
+// ataDisk reports whether a disk is attached to an ATA port
+func (m *Monitor) ataDisk(device string) bool {
+	path, err := filepath.EvalSymlinks(m.sysPath("block", device, "device"))
+	if err != nil {
+		return false
+	}
+	return ataPortRegex.MatchString(path)
+}
+
+// diskHCTL returns the SCSI host:channel:target:lun address of a disk
+func (m *Monitor) diskHCTL(device string) string {
+	target, err := os.Readlink(m.sysPath("block", device, "device"))
+	if err != nil {
+		return ""
+	}
+	hctl := filepath.Base(target)
+	if strings.Count(hctl, ":") != 3 {
+		return ""
+	}
+	return hctl
+}
+
+// diskSerial returns the serial number of a disk from its sysfs serial
+// attribute, falling back to the SCSI unit serial number VPD page
+func (m *Monitor) diskSerial(device string) string {
+	if serial := readAttr(m.sysPath("block", device, "device", "serial")); serial != "" {
+		return serial
+	}
+
+	data, err := os.ReadFile(m.sysPath("block", device, "device", "vpd_pg80"))
+	if err != nil {
+		return ""
+	}
+	return parseVPDSerial(data)
+}
+
+// diskWWN returns the world wide name of a disk in the 0x-prefixed form
+// used by the /dev/disk/by-id/wwn-* links
+func (m *Monitor) diskWWN(device string) string {
+	wwid := readAttr(m.sysPath("block", device, "device", "wwid"))
+	if wwid == "" {
+		wwid = readAttr(m.sysPath("block", device, "wwid"))
+	}
+	if !strings.HasPrefix(wwid, "naa.") {
+		return ""
+	}
+	return normalizeWWN(wwid)
+}
+
+func (m *Monitor) getProductName() string {
+	if name := readAttr(m.sysPath("class/dmi/id/product_name")); name != "" {
+		return name
+	}
+
+	// Disks are mapped outside the monitor loops, only the tool's own
+	// timeout applies
+	output, err := m.runner().Output(context.Background(), "dmidecode", "--string", "system-product-name")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// normalizeWWN converts "wwn-0x5000…", "naa.5000…" and "0X5000…" to "0x5000…"
+func normalizeWWN(wwn string) string {
+	wwn = strings.ToLower(strings.TrimPrefix(wwn, "wwn-"))
+	if strings.HasPrefix(wwn, "naa.") {
+		return "0x" + strings.TrimPrefix(wwn, "naa.")
+	}
+	return wwn
+}
+
+// parseVPDSerial extracts the product serial number from a raw VPD page 0x80
+func parseVPDSerial(data []byte) string {
+	if len(data) < 4 || data[1] != 0x80 {
+		return ""
+	}
+	length := int(data[2])<<8 | int(data[3])
+	if 4+length > len(data) {
+		length = len(data) - 4
+	}
+	return string(bytes.Trim(data[4:4+length], " \x00"))
+}
+
+// readAttr reads a sysfs attribute, returning "" if it cannot be read
+func readAttr(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
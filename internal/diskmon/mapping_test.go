@@ -0,0 +1,291 @@
+package diskmon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// fakeDisk describes a disk in a fake sysfs and /dev tree
+type fakeDisk struct {
+	name   string
+	hctl   string
+	serial string
+	vpd80  []byte
+	wwid   string
+	byID   []string
+	byPath []string
+	usb    bool // attached through USB rather than an ATA port
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// Replace stale links the way udev does when a disk comes back
+	os.Remove(path)
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+}
+
+// buildFakeDisks creates /sys/block, /sys/class/block and /dev/disk entries
+// for the given disks below root
+func buildFakeDisks(t *testing.T, root string, disks []fakeDisk) {
+	t.Helper()
+	for _, d := range disks {
+		host := strings.SplitN(d.hctl, ":", 2)[0]
+		port, _ := strconv.Atoi(host)
+		devDir := filepath.Join(root, "sys", "devices", "pci0000:00", "0000:00:17.0", fmt.Sprintf("ata%d", port+1), "host"+host, "target"+host+":0:0", d.hctl)
+		if d.usb {
+			devDir = filepath.Join(root, "sys", "devices", "pci0000:00", "0000:00:14.0", "usb2", "2-1", "2-1:1.0", "host"+host, "target"+host+":0:0", d.hctl)
+		}
+		blockDir := filepath.Join(devDir, "block", d.name)
+		writeFile(t, filepath.Join(blockDir, "stat"), "0 0 0 0 0 0 0 0 0 0 0\n")
+		symlink(t, devDir, filepath.Join(blockDir, "device"))
+		symlink(t, blockDir, filepath.Join(root, "sys", "block", d.name))
+		symlink(t, blockDir, filepath.Join(root, "sys", "class", "block", d.name))
+
+		if d.serial != "" {
+			writeFile(t, filepath.Join(devDir, "serial"), d.serial+"\n")
+		}
+		if d.vpd80 != nil {
+			writeFile(t, filepath.Join(devDir, "vpd_pg80"), string(d.vpd80))
+		}
+		if d.wwid != "" {
+			writeFile(t, filepath.Join(devDir, "wwid"), d.wwid+"\n")
+		}
+		for _, id := range d.byID {
+			symlink(t, "../../"+d.name, filepath.Join(root, "dev", "disk", "by-id", id))
+			symlink(t, "../../"+d.name+"1", filepath.Join(root, "dev", "disk", "by-id", id+"-part1"))
+		}
+		for _, p := range d.byPath {
+			symlink(t, "../../"+d.name, filepath.Join(root, "dev", "disk", "by-path", p))
+		}
+	}
+}
+
+func vpdPage80(serial string) []byte {
+	padded := "    " + serial
+	return append([]byte{0x00, 0x80, 0x00, byte(len(padded))}, padded...)
+}
+
+func testDisks() []fakeDisk {
+	return []fakeDisk{
+		{
+			name:   "sda",
+			hctl:   "0:0:0:0",
+			vpd80:  vpdPage80("WD-WCC7K1234567"),
+			wwid:   "naa.5000CCA264C1D2E3",
+			byID:   []string{"ata-WDC_WD40EFRX-68N32N0_WD-WCC7K1234567", "wwn-0x5000cca264c1d2e3"},
+			byPath: []string{"pci-0000:00:17.0-ata-1"},
+		},
+		{
+			name:   "sdb",
+			hctl:   "1:0:0:0",
+			serial: "ZL2ABCDE",
+			wwid:   "naa.5000c500a1b2c3d4",
+			byPath: []string{"pci-0000:00:17.0-ata-2"},
+		},
+	}
+}
+
+func TestMonitor_EnumerateDisks(t *testing.T) {
+	tests := []struct {
+		method   string
+		expected map[string]string
+	}{
+		{
+			method:   "hctl",
+			expected: map[string]string{"0:0:0:0": "sda", "1:0:0:0": "sdb"},
+		},
+		{
+			method:   "serial",
+			expected: map[string]string{"WD-WCC7K1234567": "sda", "ZL2ABCDE": "sdb"},
+		},
+		{
+			method:   "wwn",
+			expected: map[string]string{"0x5000cca264c1d2e3": "sda", "0x5000c500a1b2c3d4": "sdb"},
+		},
+		{
+			method:   "by-id",
+			expected: map[string]string{"ata-WDC_WD40EFRX-68N32N0_WD-WCC7K1234567": "sda", "wwn-0x5000cca264c1d2e3": "sda"},
+		},
+		{
+			method:   "by-path",
+			expected: map[string]string{"pci-0000:00:17.0-ata-1": "sda", "pci-0000:00:17.0-ata-2": "sdb"},
+		},
+	}
+
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			m := &Monitor{
+				cfg:      &config.DiskMonitorConfig{MappingMethod: tt.method},
+				root:     root,
+				commands: &command.Fake{},
+			}
+
+			devMap, err := m.enumerateDisks()
+			if err != nil {
+				t.Fatalf("enumerateDisks() error = %v", err)
+			}
+			if len(devMap) != len(tt.expected) {
+				t.Errorf("enumerateDisks() = %v, want %v", devMap, tt.expected)
+			}
+			for key, device := range tt.expected {
+				if devMap[key] != device {
+					t.Errorf("enumerateDisks()[%q] = %q, want %q", key, devMap[key], device)
+				}
+			}
+		})
+	}
+}
+
+func TestMonitor_EnumerateDisks_USB(t *testing.T) {
+	root := t.TempDir()
+	// A USB disk whose address, serial and WWN look like those of a bay
+	usbDisk := fakeDisk{
+		name:   "sdc",
+		hctl:   "2:0:0:0",
+		serial: "WD-WX12D3456789",
+		wwid:   "naa.50014ee2b5a6c7d8",
+		byID:   []string{"wwn-0x50014ee2b5a6c7d8"},
+		usb:    true,
+	}
+	buildFakeDisks(t, root, append(testDisks(), usbDisk))
+
+	for _, method := range []string{"hctl", "serial", "wwn"} {
+		t.Run(method, func(t *testing.T) {
+			m := &Monitor{
+				cfg:      &config.DiskMonitorConfig{MappingMethod: method},
+				root:     root,
+				commands: &command.Fake{},
+			}
+
+			devMap, err := m.enumerateDisks()
+			if err != nil {
+				t.Fatalf("enumerateDisks() error = %v", err)
+			}
+			if len(devMap) != 2 {
+				t.Errorf("enumerateDisks() = %v, want only the ATA disks", devMap)
+			}
+			for key, device := range devMap {
+				if device == "sdc" {
+					t.Errorf("enumerateDisks()[%q] = %q, want the USB disk skipped", key, device)
+				}
+			}
+		})
+	}
+}
+
+func TestMonitor_InitializeDisks_ByPath(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	for _, name := range []string{"disk1", "disk2", "disk3"} {
+		if err := os.MkdirAll(filepath.Join(root, "sys", "class", "leds", name), 0755); err != nil {
+			t.Fatalf("Failed to create LED directory: %v", err)
+		}
+	}
+
+	m := &Monitor{
+		cfg: &config.DiskMonitorConfig{
+			MappingMethod:      "by-path",
+			DiskMapping:        []string{"pci-0000:00:17.0-ata-2", "pci-0000:00:17.0-ata-1", "pci-0000:00:17.0-ata-3"},
+			ColorDiskHealth:    config.RGB{R: 255, G: 255, B: 255},
+			BrightnessDiskLeds: 255,
+		},
+		root:        root,
+		disks:       make(map[string]*diskState),
+		ledToDevice: make(map[string]string),
+		deviceToLED: make(map[string]string),
+		zpoolLEDMap: make(map[string]string),
+		commands:    &command.Fake{},
+	}
+
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	if m.ledToDevice["disk1"] != "sdb" || m.ledToDevice["disk2"] != "sda" {
+		t.Errorf("ledToDevice = %v, want disk1 -> sdb, disk2 -> sda", m.ledToDevice)
+	}
+	if _, ok := m.ledToDevice["disk3"]; ok {
+		t.Errorf("disk3 should not be mapped, got %q", m.ledToDevice["disk3"])
+	}
+	data, err := os.ReadFile(filepath.Join(root, "sys", "class", "leds", "disk3", "brightness"))
+	if err != nil || string(data) != "0" {
+		t.Errorf("disk3 brightness = %q (%v), want %q", data, err, "0")
+	}
+}
+
+func TestMonitor_InitializeDisks_MissingMapping(t *testing.T) {
+	t.Setenv("DISK_SERIAL", "")
+	for _, method := range []string{"serial", "wwn", "by-id", "by-path"} {
+		m := &Monitor{
+			cfg:      &config.DiskMonitorConfig{MappingMethod: method},
+			root:     t.TempDir(),
+			commands: &command.Fake{},
+		}
+		if err := os.MkdirAll(m.sysPath("block"), 0755); err != nil {
+			t.Fatalf("Failed to create sys/block: %v", err)
+		}
+		if err := m.initializeDisks(); err == nil {
+			t.Errorf("initializeDisks() with %s and no DISK_MAPPING should return error", method)
+		}
+	}
+}
+
+func TestNormalizeWWN(t *testing.T) {
+	tests := map[string]string{
+		"wwn-0x5000c500a1b2c3d4": "0x5000c500a1b2c3d4",
+		"naa.5000C500A1B2C3D4":   "0x5000c500a1b2c3d4",
+		"0X5000C500A1B2C3D4":     "0x5000c500a1b2c3d4",
+		"0x5000c500a1b2c3d4":     "0x5000c500a1b2c3d4",
+	}
+	for input, expected := range tests {
+		if result := normalizeWWN(input); result != expected {
+			t.Errorf("normalizeWWN(%q) = %q, want %q", input, result, expected)
+		}
+	}
+}
+
+func TestParseVPDSerial(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{name: "padded serial", input: vpdPage80("ZL2ABCDE"), expected: "ZL2ABCDE"},
+		{name: "trailing nul", input: []byte{0x00, 0x80, 0x00, 0x04, 'A', 'B', 'C', 0x00}, expected: "ABC"},
+		{name: "truncated page", input: []byte{0x00, 0x80, 0x00, 0x10, 'A', 'B'}, expected: "AB"},
+		{name: "wrong page", input: []byte{0x00, 0x83, 0x00, 0x02, 'A', 'B'}, expected: ""},
+		{name: "too short", input: []byte{0x00, 0x80}, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := parseVPDSerial(tt.input); result != tt.expected {
+				t.Errorf("parseVPDSerial() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
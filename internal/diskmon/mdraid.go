@@ -0,0 +1,309 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/blockdev"
+)
+
+var (
+	mdstatArray    = regexp.MustCompile(`^(md\S+)\s*:\s*(\S+)\s*(.*)$`)
+	mdstatMember   = regexp.MustCompile(`^(\S+)\[\d+\]((?:\([A-Z]\))*)$`)
+	mdstatProgress = regexp.MustCompile(`(resync|recovery|check|repair|reshape)\s*=\s*([\d.]+)%`)
+	mdstatDegraded = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+)
+
+// mdArray is a Linux md RAID array
+type mdArray struct {
+	name       string // kernel name, e.g. md0
+	state      string // array_state, e.g. clean or active
+	degraded   int    // missing devices
+	syncAction string // idle, resync, recover, check, repair or reshape
+	progress   float64
+	members    []mdDevice
+}
+
+// mdDevice is a member device of an md array
+type mdDevice struct {
+	name   string // kernel name, e.g. sda1
+	faulty bool
+	spare  bool // an idle spare without a role in the array
+	inSync bool
+}
+
+// rebuilding reports whether the device is being recovered onto
+func (d mdDevice) rebuilding() bool {
+	return !d.faulty && !d.spare && !d.inSync
+}
+
+// mdMember is the md array membership of a disk, as shown on its LED
+type mdMember struct {
+	array      string
+	arrayState string
+	degraded   int
+	device     string
+	faulty     bool
+	rebuilding bool // a spare being rebuilt onto
+	syncAction string
+	progress   float64
+}
+
+// display returns the display state for the disk's array membership. md
+// arrays use the same colors as ZFS pools.
+func (md mdMember) display() displayState {
+	switch {
+	case md.faulty || md.arrayState == "broken":
+		return displayZpoolFault
+	case md.syncAction == "resync" || md.syncAction == "reshape" || md.rebuilding:
+		return displayResilver
+	case md.syncAction == "check" || md.syncAction == "repair":
+		return displayScrub
+	case md.degraded > 0:
+		return displayPoolDegraded
+	}
+	return displayHealthy
+}
+
+func (m *Monitor) mdraidCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckMdraidInterval
+	if interval <= 0 {
+		interval = 5 // Default to 5 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkMdraid()
+		}
+	}
+}
+
+func (m *Monitor) checkMdraid() {
+	data, err := os.ReadFile(m.procPath("mdstat"))
+	if err != nil {
+		return
+	}
+
+	arrays := parseMDStat(string(data))
+	for i := range arrays {
+		m.readMDSysfs(&arrays[i])
+	}
+	m.applyMDStatus(arrays)
+}
+
+// applyMDStatus updates the array membership of every mapped disk
+func (m *Monitor) applyMDStatus(arrays []mdArray) {
+	m.logMDSyncs(arrays)
+
+	// A disk with several member partitions shows the worst of them
+	members := make(map[string]mdMember)
+	for _, array := range arrays {
+		// /proc/mdstat does not say which devices a recovery rebuilds, so
+		// without sysfs it shows on every active member
+		marked := false
+		for _, dev := range array.members {
+			marked = marked || dev.rebuilding()
+		}
+
+		for _, dev := range array.members {
+			ledNames := m.diskLEDs(blockdev.Disks(m.root, dev.name))
+			if len(ledNames) == 0 {
+				continue
+			}
+
+			member := mdMember{
+				array:      array.name,
+				arrayState: array.state,
+				degraded:   array.degraded,
+				device:     dev.name,
+				faulty:     dev.faulty,
+				syncAction: array.syncAction,
+				progress:   array.progress,
+			}
+			if array.syncAction == "recover" {
+				// A recovery only shows on the devices being rebuilt
+				member.syncAction = ""
+				member.rebuilding = dev.rebuilding() || (!marked && !dev.faulty && !dev.spare)
+			}
+			for _, ledName := range ledNames {
+				if prev, seen := members[ledName]; !seen || member.display() > prev.display() {
+					members[ledName] = member
+				}
+			}
+		}
+	}
+
+	m.mu.RLock()
+	disks := make(map[string]*diskState, len(m.ledToDevice))
+	for ledName, device := range m.ledToDevice {
+		disks[ledName] = m.disks[device]
+	}
+	m.mu.RUnlock()
+
+	for ledName, state := range disks {
+		// Disks missing from mdstat are not (or no longer) array members
+		member := members[ledName]
+
+		state.mu.RLock()
+		prev := state.md
+		state.mu.RUnlock()
+		if prev == member {
+			continue
+		}
+
+		m.update(state, func(s *diskState) {
+			s.md = member
+		})
+
+		level, prevLevel := member.display(), prev.display()
+		if level == prevLevel {
+			continue
+		}
+
+		now := m.clock().Now().Format("2006-01-02 15:04:05")
+		switch {
+		case level == displayZpoolFault:
+			log.Printf("MDRAID Disk failure detected on /dev/%s (array %s %s) at %s", member.device, member.array, member.arrayState, now)
+			reason := "faulty"
+			if !member.faulty {
+				reason = member.arrayState
+			}
+			m.recordEvent(state, "md", fmt.Sprintf("%s in array %s", reason, member.array))
+		case level != displayHealthy:
+			log.Printf("MDRAID warning on /dev/%s: %s (array %s, %d degraded) at %s", member.device, level, member.array, member.degraded, now)
+		case prevLevel == displayZpoolFault:
+			log.Printf("MDRAID Disk /dev/%s recovered at %s", state.device, now)
+			m.recordEvent(state, "md-recovered", "array "+prev.array)
+		default:
+			log.Printf("MDRAID warning on /dev/%s cleared at %s", state.device, now)
+		}
+	}
+}
+
+// logMDSyncs logs array syncs starting and finishing. It is only called
+// from the md RAID check loop.
+func (m *Monitor) logMDSyncs(arrays []mdArray) {
+	if m.mdSyncs == nil {
+		m.mdSyncs = make(map[string]string)
+	}
+
+	now := m.clock().Now().Format("2006-01-02 15:04:05")
+	for _, array := range arrays {
+		action, prev := array.syncAction, m.mdSyncs[array.name]
+		m.mdSyncs[array.name] = action
+		switch {
+		case action != "" && action != prev:
+			log.Printf("MDRAID %s started on array %s at %s", action, array.name, now)
+		case action == "" && prev != "":
+			log.Printf("MDRAID %s finished on array %s at %s", prev, array.name, now)
+		}
+	}
+}
+
+// parseMDStat parses /proc/mdstat into its arrays, with the member flags
+// and sync progress it shows
+func parseMDStat(mdstat string) []mdArray {
+	var arrays []mdArray
+	var current *mdArray
+	for _, line := range strings.Split(mdstat, "\n") {
+		if match := mdstatArray.FindStringSubmatch(line); match != nil {
+			arrays = append(arrays, mdArray{name: match[1], state: match[2]})
+			current = &arrays[len(arrays)-1]
+			for _, field := range strings.Fields(match[3]) {
+				dev := mdstatMember.FindStringSubmatch(field)
+				if dev == nil {
+					continue // raid level or "(auto-read-only)"
+				}
+				d := mdDevice{
+					name:   dev[1],
+					faulty: strings.Contains(dev[2], "(F)"),
+					spare:  strings.Contains(dev[2], "(S)"),
+				}
+				d.inSync = !d.faulty && !d.spare
+				current.members = append(current.members, d)
+			}
+			continue
+		}
+		if current == nil || !strings.HasPrefix(line, " ") {
+			current = nil
+			continue
+		}
+
+		if match := mdstatDegraded.FindStringSubmatch(line); match != nil {
+			total, _ := strconv.Atoi(match[1])
+			up, _ := strconv.Atoi(match[2])
+			current.degraded = total - up
+		}
+		if match := mdstatProgress.FindStringSubmatch(line); match != nil {
+			current.syncAction = match[1]
+			if current.syncAction == "recovery" {
+				current.syncAction = "recover"
+			}
+			if percent, err := strconv.ParseFloat(match[2], 64); err == nil {
+				current.progress = percent / 100
+			}
+		}
+	}
+	return arrays
+}
+
+// readMDSysfs refines an array from /sys/block/<array>/md, which has the
+// exact array and member states. It keeps the mdstat values for anything
+// sysfs does not have.
+func (m *Monitor) readMDSysfs(array *mdArray) {
+	dir := m.sysPath("block", array.name, "md")
+	if state := readAttr(filepath.Join(dir, "array_state")); state != "" {
+		array.state = state
+	}
+	if n, err := strconv.Atoi(readAttr(filepath.Join(dir, "degraded"))); err == nil {
+		array.degraded = n
+	}
+	if action := readAttr(filepath.Join(dir, "sync_action")); action != "" {
+		if action == "idle" || action == "frozen" {
+			action = ""
+			array.progress = 0
+		}
+		array.syncAction = action
+	}
+	var done, total float64
+	if n, _ := fmt.Sscanf(readAttr(filepath.Join(dir, "sync_completed")), "%g / %g", &done, &total); n == 2 && total > 0 {
+		array.progress = done / total
+	}
+
+	states, _ := filepath.Glob(filepath.Join(dir, "dev-*", "state"))
+	if len(states) == 0 {
+		return
+	}
+	var members []mdDevice
+	for _, path := range states {
+		devDir := filepath.Dir(path)
+		dev := mdDevice{
+			name: strings.TrimPrefix(filepath.Base(devDir), "dev-"),
+			// Devices without a slot are idle spares, a spare with a slot
+			// is being rebuilt
+			spare: readAttr(filepath.Join(devDir, "slot")) == "none",
+		}
+		for _, flag := range strings.Split(readAttr(path), ",") {
+			switch flag {
+			case "faulty":
+				dev.faulty = true
+			case "in_sync":
+				dev.inSync = true
+			}
+		}
+		members = append(members, dev)
+	}
+	array.members = members
+}
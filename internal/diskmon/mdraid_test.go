@@ -0,0 +1,166 @@
+package diskmon
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+const mdstatRecovery = `Personalities : [raid1] [raid6] [raid5] [raid4]
+md1 : active raid5 sdd1[3] sdc1[1](F) sdb1[0]
+      7813771264 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [U_U]
+      [==>..................]  recovery = 12.6% (492237952/3906885632) finish=321.5min speed=176995K/sec
+      bitmap: 2/30 pages [8KB], 65536KB chunk
+
+md0 : active raid1 sde1[2](S) sda1[0] sdb2[1]
+      976630464 blocks super 1.2 [2/2] [UU]
+      [=========>...........]  check = 47.1% (460115968/976630464) finish=44.6min speed=192947K/sec
+
+unused devices: <none>
+`
+
+func TestParseMDStat(t *testing.T) {
+	arrays := parseMDStat(mdstatRecovery)
+	if len(arrays) != 2 {
+		t.Fatalf("parseMDStat() returned %d arrays, want 2: %+v", len(arrays), arrays)
+	}
+
+	md1 := arrays[0]
+	if md1.name != "md1" || md1.state != "active" || md1.degraded != 1 || md1.syncAction != "recover" || md1.progress < 0.1259 || md1.progress > 0.1261 {
+		t.Errorf("md1 = %+v", md1)
+	}
+	expected := []mdDevice{
+		{name: "sdd1", inSync: true},
+		{name: "sdc1", faulty: true},
+		{name: "sdb1", inSync: true},
+	}
+	if len(md1.members) != len(expected) {
+		t.Fatalf("md1 members = %+v, want %+v", md1.members, expected)
+	}
+	for i := range expected {
+		if md1.members[i] != expected[i] {
+			t.Errorf("md1 member %d = %+v, want %+v", i, md1.members[i], expected[i])
+		}
+	}
+
+	md0 := arrays[1]
+	if md0.degraded != 0 || md0.syncAction != "check" || md0.progress < 0.4709 || md0.progress > 0.4711 {
+		t.Errorf("md0 = %+v", md0)
+	}
+	if spare := md0.members[0]; spare.name != "sde1" || !spare.spare || spare.inSync {
+		t.Errorf("md0 spare = %+v", spare)
+	}
+}
+
+func TestMDMember_Display(t *testing.T) {
+	tests := []struct {
+		name     string
+		member   mdMember
+		expected displayState
+	}{
+		{name: "not a member", member: mdMember{}, expected: displayHealthy},
+		{name: "clean", member: mdMember{array: "md0", arrayState: "clean"}, expected: displayHealthy},
+		{name: "degraded", member: mdMember{array: "md0", degraded: 1}, expected: displayPoolDegraded},
+		{name: "check", member: mdMember{array: "md0", syncAction: "check", degraded: 1}, expected: displayScrub},
+		{name: "rebuilding", member: mdMember{array: "md0", rebuilding: true, degraded: 1}, expected: displayResilver},
+		{name: "faulty", member: mdMember{array: "md0", faulty: true, rebuilding: true}, expected: displayZpoolFault},
+		{name: "broken", member: mdMember{array: "md0", arrayState: "broken"}, expected: displayZpoolFault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.member.display(); result != tt.expected {
+				t.Errorf("display() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_CheckMdraid(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0"},
+		{name: "sdc", hctl: "2:0:0:0", serial: "WD-WCC7K7654321"},
+		{name: "sdd", hctl: "3:0:0:0"},
+	})
+	writeFile(t, filepath.Join(root, "proc", "mdstat"), mdstatRecovery)
+
+	// sysfs knows that sdd1 is the device being rebuilt
+	md1 := filepath.Join(root, "sys", "block", "md1", "md")
+	writeFile(t, filepath.Join(md1, "array_state"), "clean\n")
+	writeFile(t, filepath.Join(md1, "degraded"), "1\n")
+	writeFile(t, filepath.Join(md1, "sync_action"), "recover\n")
+	writeFile(t, filepath.Join(md1, "sync_completed"), "492237952 / 3906885632\n")
+	for dev, state := range map[string][2]string{
+		"sdb1": {"in_sync", "0"},
+		"sdc1": {"faulty", "1"},
+		"sdd1": {"spare", "1"},
+	} {
+		writeFile(t, filepath.Join(md1, "dev-"+dev, "state"), state[0]+"\n")
+		writeFile(t, filepath.Join(md1, "dev-"+dev, "slot"), state[1]+"\n")
+	}
+
+	cfg := hotplugConfig()
+	cfg.CheckMdraid = true
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 0}
+	cfg.ColorZpoolPoolDegraded = config.RGB{R: 255, G: 255, B: 0}
+	cfg.ColorZpoolScrub = config.RGB{R: 0, G: 255, B: 255}
+	cfg.ColorZpoolResilver = config.RGB{R: 255, G: 0, B: 255}
+	m := newTestMonitor(t, root, cfg)
+	store, _ := openHistory(t)
+	m.history = store
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkMdraid()
+
+	expected := map[string]struct {
+		shown displayState
+		color string
+	}{
+		"sda": {displayScrub, "0 255 255"},
+		// sdb is in both arrays and shows the worse of the two
+		"sdb": {displayScrub, "0 255 255"},
+		"sdc": {displayZpoolFault, "255 0 0"},
+		"sdd": {displayResilver, "255 0 255"},
+	}
+	for device, want := range expected {
+		if state := m.disks[device]; state.shown != want.shown {
+			t.Errorf("%s shown = %v, want %v", device, state.shown, want.shown)
+		}
+		if color := readLED(t, root, m.deviceToLED[device], "color"); color != want.color {
+			t.Errorf("%s color = %q, want %q", device, color, want.color)
+		}
+	}
+	if progress := m.disks["sdd"].md.progress; progress < 0.125 || progress > 0.127 {
+		t.Errorf("sdd progress = %v, want 0.126", progress)
+	}
+
+	// Once the arrays are rebuilt and checked every disk recovers
+	writeFile(t, filepath.Join(root, "proc", "mdstat"), `Personalities : [raid1] [raid5]
+md1 : active raid5 sdd1[3] sdc1[1] sdb1[0]
+      7813771264 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/3] [UUU]
+
+md0 : active raid1 sda1[0] sdb2[1]
+      976630464 blocks super 1.2 [2/2] [UU]
+
+unused devices: <none>
+`)
+	writeFile(t, filepath.Join(md1, "degraded"), "0\n")
+	writeFile(t, filepath.Join(md1, "sync_action"), "idle\n")
+	writeFile(t, filepath.Join(md1, "dev-sdc1", "state"), "in_sync\n")
+	writeFile(t, filepath.Join(md1, "dev-sdd1", "state"), "in_sync\n")
+	m.checkMdraid()
+	for device := range expected {
+		if state := m.disks[device]; state.shown != displayHealthy {
+			t.Errorf("%s shown = %v after recovery, want %v", device, state.shown, displayHealthy)
+		}
+	}
+
+	events := store.Events("WD-WCC7K7654321")
+	if len(events) != 2 || events[0].Kind != "md" || events[0].Detail != "faulty in array md1" || events[1].Kind != "md-recovered" {
+		t.Errorf("Events() = %+v, want sdc failing and recovering in md1", events)
+	}
+}
@@ -0,0 +1,152 @@
+package diskmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+)
+
+var (
+	nvmeNamespaceRegex = regexp.MustCompile(`^nvme\d+n\d+$`)
+	nvmeCtrlRegex      = regexp.MustCompile(`/nvme/(nvme\d+)/`)
+	pciAddrRegex       = regexp.MustCompile(`[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]`)
+)
+
+// nvmeHealth holds the fields of the NVMe SMART / health information log
+// that decide whether a drive is failing
+type nvmeHealth struct {
+	CriticalWarning int
+	AvailableSpare  int
+	SpareThreshold  int
+	MediaErrors     uint64
+}
+
+// isNVMe reports whether device is an NVMe namespace block device
+func isNVMe(device string) bool {
+	return nvmeNamespaceRegex.MatchString(device)
+}
+
+// nvmeController returns the controller name (nvme0) and PCI address
+// (0000:01:00.0) of an NVMe namespace from its /sys/block link
+func (m *Monitor) nvmeController(device string) (string, string) {
+	if !isNVMe(device) {
+		return "", ""
+	}
+
+	linkTarget, err := os.Readlink(m.sysPath("block", device))
+	if err != nil {
+		return "", ""
+	}
+	if ctrl, pciAddr := parseNVMePath(linkTarget); ctrl != "" {
+		return ctrl, pciAddr
+	}
+
+	// Native multipath namespaces hang off a virtual subsystem, the hidden
+	// namespaces of their paths hang off the controllers
+	paths, err := os.ReadDir(m.sysPath("block", device, "multipath"))
+	if err != nil || len(paths) == 0 {
+		return "", ""
+	}
+	pathTarget, err := filepath.EvalSymlinks(m.sysPath("block", device, "multipath", paths[0].Name()))
+	if err != nil {
+		return "", ""
+	}
+	return parseNVMePath(pathTarget)
+}
+
+// parseNVMePath returns the controller name and PCI address in the sysfs
+// path of a namespace, or "", "" if it is not below a PCI controller
+func parseNVMePath(path string) (string, string) {
+	ctrl := nvmeCtrlRegex.FindStringSubmatch(path)
+	pciAddrs := pciAddrRegex.FindAllString(path, -1)
+	if ctrl == nil || len(pciAddrs) == 0 {
+		return "", ""
+	}
+	return ctrl[1], pciAddrs[len(pciAddrs)-1]
+}
+
+// checkNVMeHealth reads the SMART log of an NVMe namespace with smartctl,
+// falling back to nvme-cli, and returns the reason the drive is failing or
+// "" if it is healthy
+func (m *Monitor) checkNVMeHealth(ctx context.Context, device string) (string, error) {
+	output, err := m.runner().Output(ctx, "smartctl", "-j", "-H", "-A", "/dev/"+device)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !command.IsTimeout(err) {
+		output, err = m.runner().Output(ctx, "nvme", "smart-log", "-o", "json", "/dev/"+device)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	health, err := parseNVMeHealth(output)
+	if err != nil {
+		return "", err
+	}
+	return health.problem(), nil
+}
+
+// parseNVMeHealth parses the health log from either `smartctl -j` or
+// `nvme smart-log -o json` output
+func parseNVMeHealth(data []byte) (*nvmeHealth, error) {
+	var smartctl struct {
+		Log *struct {
+			CriticalWarning int    `json:"critical_warning"`
+			AvailableSpare  int    `json:"available_spare"`
+			SpareThreshold  int    `json:"available_spare_threshold"`
+			MediaErrors     uint64 `json:"media_errors"`
+		} `json:"nvme_smart_health_information_log"`
+	}
+	if err := json.Unmarshal(data, &smartctl); err != nil {
+		return nil, fmt.Errorf("failed to parse NVMe health log: %w", err)
+	}
+	if l := smartctl.Log; l != nil {
+		return &nvmeHealth{
+			CriticalWarning: l.CriticalWarning,
+			AvailableSpare:  l.AvailableSpare,
+			SpareThreshold:  l.SpareThreshold,
+			MediaErrors:     l.MediaErrors,
+		}, nil
+	}
+
+	var nvmeCLI struct {
+		CriticalWarning *int   `json:"critical_warning"`
+		AvailableSpare  int    `json:"avail_spare"`
+		SpareThreshold  int    `json:"spare_thresh"`
+		MediaErrors     uint64 `json:"media_errors"`
+	}
+	if err := json.Unmarshal(data, &nvmeCLI); err != nil {
+		return nil, fmt.Errorf("failed to parse NVMe health log: %w", err)
+	}
+	if nvmeCLI.CriticalWarning == nil {
+		return nil, fmt.Errorf("no NVMe health log in output")
+	}
+	return &nvmeHealth{
+		CriticalWarning: *nvmeCLI.CriticalWarning,
+		AvailableSpare:  nvmeCLI.AvailableSpare,
+		SpareThreshold:  nvmeCLI.SpareThreshold,
+		MediaErrors:     nvmeCLI.MediaErrors,
+	}, nil
+}
+
+// problem describes why the drive is failing, or returns "" if it is healthy
+func (h *nvmeHealth) problem() string {
+	var reasons []string
+	if h.CriticalWarning != 0 {
+		reasons = append(reasons, fmt.Sprintf("critical warning 0x%02x", h.CriticalWarning))
+	}
+	if h.SpareThreshold > 0 && h.AvailableSpare < h.SpareThreshold {
+		reasons = append(reasons, fmt.Sprintf("available spare %d%% below threshold %d%%", h.AvailableSpare, h.SpareThreshold))
+	}
+	if h.MediaErrors > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d media errors", h.MediaErrors))
+	}
+	return strings.Join(reasons, ", ")
+}
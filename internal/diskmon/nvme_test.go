@@ -0,0 +1,170 @@
+package diskmon
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// buildFakeNVMe creates the sysfs entries of an NVMe namespace below root
+func buildFakeNVMe(t *testing.T, root, pciAddr, ctrl, namespace string) {
+	t.Helper()
+	blockDir := filepath.Join(root, "sys", "devices", "pci0000:00", "0000:00:1d.0", pciAddr, "nvme", ctrl, namespace)
+	writeFile(t, filepath.Join(blockDir, "stat"), "0 0 0 0 0 0 0 0 0 0 0\n")
+	symlink(t, blockDir, filepath.Join(root, "sys", "block", namespace))
+	symlink(t, blockDir, filepath.Join(root, "sys", "class", "block", namespace))
+}
+
+// buildFakeMultipathNVMe creates the sysfs entries of a namespace of a
+// native multipath subsystem, reached through one controller
+func buildFakeMultipathNVMe(t *testing.T, root, pciAddr, ctrl, namespace string) {
+	t.Helper()
+	blockDir := filepath.Join(root, "sys", "devices", "virtual", "nvme-subsystem", "nvme-subsys0", namespace)
+	writeFile(t, filepath.Join(blockDir, "stat"), "0 0 0 0 0 0 0 0 0 0 0\n")
+	symlink(t, blockDir, filepath.Join(root, "sys", "block", namespace))
+	symlink(t, blockDir, filepath.Join(root, "sys", "class", "block", namespace))
+
+	// The hidden namespace of the path, nvme0c0n1 for nvme0n1 through nvme0
+	path := strings.Replace(namespace, "n", "c0n", 1)
+	pathDir := filepath.Join(root, "sys", "devices", "pci0000:00", "0000:00:1d.0", pciAddr, "nvme", ctrl, path)
+	writeFile(t, filepath.Join(pathDir, "stat"), "0 0 0 0 0 0 0 0 0 0 0\n")
+	symlink(t, pathDir, filepath.Join(blockDir, "multipath", path))
+}
+
+func TestMonitor_EnumerateDisks_NVMe(t *testing.T) {
+	root := t.TempDir()
+	buildFakeNVMe(t, root, "0000:01:00.0", "nvme0", "nvme0n1")
+	buildFakeNVMe(t, root, "0000:01:00.0", "nvme0", "nvme0n2")
+	buildFakeNVMe(t, root, "0000:02:00.0", "nvme1", "nvme1n1")
+	buildFakeDisks(t, root, testDisks())
+
+	m := &Monitor{
+		cfg:  &config.DiskMonitorConfig{MappingMethod: "nvme"},
+		root: root,
+	}
+
+	devMap, err := m.enumerateDisks()
+	if err != nil {
+		t.Fatalf("enumerateDisks() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"nvme0":        "nvme0n1",
+		"0000:01:00.0": "nvme0n1",
+		"nvme1":        "nvme1n1",
+		"0000:02:00.0": "nvme1n1",
+	}
+	if len(devMap) != len(expected) {
+		t.Errorf("enumerateDisks() = %v, want %v", devMap, expected)
+	}
+	for key, device := range expected {
+		if devMap[key] != device {
+			t.Errorf("enumerateDisks()[%q] = %q, want %q", key, devMap[key], device)
+		}
+	}
+}
+
+func TestMonitor_EnumerateDisks_NVMeMultipath(t *testing.T) {
+	root := t.TempDir()
+	buildFakeMultipathNVMe(t, root, "0000:01:00.0", "nvme0", "nvme0n1")
+	buildFakeDisks(t, root, testDisks())
+
+	m := &Monitor{
+		cfg:  &config.DiskMonitorConfig{MappingMethod: "nvme"},
+		root: root,
+	}
+
+	devMap, err := m.enumerateDisks()
+	if err != nil {
+		t.Fatalf("enumerateDisks() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"nvme0":        "nvme0n1",
+		"0000:01:00.0": "nvme0n1",
+	}
+	if len(devMap) != len(expected) {
+		t.Errorf("enumerateDisks() = %v, want %v", devMap, expected)
+	}
+	for key, device := range expected {
+		if devMap[key] != device {
+			t.Errorf("enumerateDisks()[%q] = %q, want %q", key, devMap[key], device)
+		}
+	}
+}
+
+func TestIsNVMe(t *testing.T) {
+	tests := map[string]bool{
+		"nvme0n1":   true,
+		"nvme10n2":  true,
+		"nvme0n1p1": false,
+		"nvme0":     false,
+		"sda":       false,
+	}
+	for device, expected := range tests {
+		if result := isNVMe(device); result != expected {
+			t.Errorf("isNVMe(%q) = %v, want %v", device, result, expected)
+		}
+	}
+}
+
+func TestParseNVMeHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected nvmeHealth
+		problem  bool
+		wantErr  bool
+	}{
+		{
+			name: "smartctl healthy",
+			input: `{"smart_status":{"passed":true},"nvme_smart_health_information_log":{
+				"critical_warning":0,"temperature":38,"available_spare":100,
+				"available_spare_threshold":10,"percentage_used":2,"media_errors":0}}`,
+			expected: nvmeHealth{AvailableSpare: 100, SpareThreshold: 10},
+		},
+		{
+			name: "smartctl spare exhausted",
+			input: `{"nvme_smart_health_information_log":{"critical_warning":1,
+				"available_spare":5,"available_spare_threshold":10,"media_errors":0}}`,
+			expected: nvmeHealth{CriticalWarning: 1, AvailableSpare: 5, SpareThreshold: 10},
+			problem:  true,
+		},
+		{
+			name:     "nvme-cli media errors",
+			input:    `{"critical_warning":0,"temperature":311,"avail_spare":100,"spare_thresh":10,"percent_used":1,"media_errors":3}`,
+			expected: nvmeHealth{AvailableSpare: 100, SpareThreshold: 10, MediaErrors: 3},
+			problem:  true,
+		},
+		{
+			name:    "no health log",
+			input:   `{"smartctl":{"exit_status":2}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			input:   `not json`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health, err := parseNVMeHealth([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNVMeHealth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *health != tt.expected {
+				t.Errorf("parseNVMeHealth() = %+v, want %+v", *health, tt.expected)
+			}
+			if (health.problem() != "") != tt.problem {
+				t.Errorf("problem() = %q, want problem %v", health.problem(), tt.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package diskmon
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// diskstatsSampler reads /proc/diskstats once per tick for all disks. The
+// file stays open and is read into a buffer that is reused, so a tick does
+// not allocate.
+type diskstatsSampler struct {
+	path string
+	file *os.File
+	buf  []byte
+}
+
+func newDiskstatsSampler(path string) *diskstatsSampler {
+	// Each line is about 100 bytes and partitions have lines too
+	return &diskstatsSampler{path: path, buf: make([]byte, 16*1024)}
+}
+
+// read returns the current contents of /proc/diskstats. The result is only
+// valid until the next call.
+func (s *diskstatsSampler) read() ([]byte, error) {
+	if s.file == nil {
+		f, err := os.Open(s.path)
+		if err != nil {
+			return nil, err
+		}
+		s.file = f
+	}
+
+	for {
+		// Reading from offset 0 makes procfs generate the file again
+		n, err := s.file.ReadAt(s.buf, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			s.close()
+			return nil, err
+		}
+		if n < len(s.buf) {
+			return s.buf[:n], nil
+		}
+		// The buffer may have cut the file short
+		s.buf = make([]byte, 2*len(s.buf))
+	}
+}
+
+func (s *diskstatsSampler) close() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+// forEachDiskstat calls fn with the device name and counters of each line
+// of /proc/diskstats. name is only valid during the call.
+func forEachDiskstat(data []byte, fn func(name []byte, c ioCounters)) {
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+
+		// major minor name, followed by the fields of the stat file
+		_, line = nextField(line)
+		_, line = nextField(line)
+		name, line := nextField(line)
+		if len(name) == 0 {
+			continue
+		}
+
+		var c ioCounters
+		for i := 0; len(line) > 0; i++ {
+			var field []byte
+			field, line = nextField(line)
+			switch i {
+			case 0:
+				c.reads = parseUint(field)
+			case 2:
+				c.sectorsRead = parseUint(field)
+			case 3:
+				c.readTicks = parseUint(field)
+			case 4:
+				c.writes = parseUint(field)
+			case 6:
+				c.sectorsWritten = parseUint(field)
+			case 7:
+				c.writeTicks = parseUint(field)
+			case 8:
+				c.inFlight = parseUint(field)
+			case 9:
+				c.ioTicks = parseUint(field)
+			case 11:
+				c.discards = parseUint(field)
+			case 15:
+				c.flushes = parseUint(field)
+			}
+		}
+		fn(name, c)
+	}
+}
+
+// nextField splits the first space separated field off b
+func nextField(b []byte) (field, rest []byte) {
+	start := 0
+	for start < len(b) && b[start] == ' ' {
+		start++
+	}
+	end := start
+	for end < len(b) && b[end] != ' ' {
+		end++
+	}
+	return b[start:end], b[end:]
+}
+
+// parseUint parses a decimal counter, ignoring anything after its digits
+func parseUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			break
+		}
+		v = v*10 + uint64(c-'0')
+	}
+	return v
+}
@@ -0,0 +1,144 @@
+package diskmon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeDiskstats writes a /proc/diskstats below root with a line for device
+// and its first partition, both with the given stat fields
+func writeDiskstats(t testing.TB, root, device, stat string) {
+	t.Helper()
+	content := fmt.Sprintf("   8       0 %s %s\n   8       1 %s1 %s\n", device, stat, device, stat)
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		t.Fatalf("Failed to create proc directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "proc", "diskstats"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write diskstats: %v", err)
+	}
+}
+
+func TestForEachDiskstat(t *testing.T) {
+	data := `   8       0 sda 4521 12 360212 2345 9876 543 1234567 8765 2 9000 11110 17 0 4096 3 42 10
+   8       1 sda1 4400 12 350000 2300 9800 543 1234000 8700 0 8900 11000 17 0 4096 3 0 0
+ 259       0 nvme0n1 4521 12 360212 2345 9876 543 1234567 8765 0 9000 11110
+   7       0 loop0 1 0 2`
+
+	result := make(map[string]ioCounters)
+	forEachDiskstat([]byte(data), func(name []byte, c ioCounters) {
+		result[string(name)] = c
+	})
+
+	expected := map[string]ioCounters{
+		"sda":     {reads: 4521, sectorsRead: 360212, readTicks: 2345, writes: 9876, sectorsWritten: 1234567, writeTicks: 8765, inFlight: 2, ioTicks: 9000, discards: 17, flushes: 42},
+		"sda1":    {reads: 4400, sectorsRead: 350000, readTicks: 2300, writes: 9800, sectorsWritten: 1234000, writeTicks: 8700, ioTicks: 8900, discards: 17},
+		"nvme0n1": {reads: 4521, sectorsRead: 360212, readTicks: 2345, writes: 9876, sectorsWritten: 1234567, writeTicks: 8765, ioTicks: 9000},
+		"loop0":   {reads: 1, sectorsRead: 2},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("forEachDiskstat() = %+v, want %+v", result, expected)
+	}
+}
+
+func TestDiskstatsSampler_Read(t *testing.T) {
+	root := t.TempDir()
+	writeDiskstats(t, root, "sda", "1 0 8 0 0 0 0 0 0 1 1")
+	s := newDiskstatsSampler(filepath.Join(root, "proc", "diskstats"))
+	defer s.close()
+	// Start small to check that the buffer grows
+	s.buf = make([]byte, 16)
+
+	data, err := s.read()
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if !strings.HasPrefix(string(data), "   8       0 sda 1 0 8") || !strings.HasSuffix(string(data), "sda1 1 0 8 0 0 0 0 0 0 1 1\n") {
+		t.Errorf("read() = %q, want the whole file", data)
+	}
+
+	// The file stays open and is read again from the start
+	writeDiskstats(t, root, "sda", "2 0 16 0 0 0 0 0 0 2 2")
+	data, err = s.read()
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if !strings.Contains(string(data), " sda 2 0 16 ") {
+		t.Errorf("read() = %q, want the new counters", data)
+	}
+
+	if _, err := newDiskstatsSampler(filepath.Join(root, "missing")).read(); err == nil {
+		t.Error("read() of a missing file should fail")
+	}
+}
+
+// buildBenchBays creates eight disks with diskstats and stat files below root
+func buildBenchBays(b *testing.B, root string) *Monitor {
+	b.Helper()
+	var diskstats strings.Builder
+	m := &Monitor{
+		cfg:   hotplugConfig(),
+		root:  root,
+		disks: make(map[string]*diskState),
+	}
+	for i := 0; i < 8; i++ {
+		device := fmt.Sprintf("sd%c", 'a'+i)
+		stat := fmt.Sprintf("%d 0 %d 0 %d 0 %d 0 0 %d 0 0 0 0 0 0 0", 100+i, 800+i, 50+i, 400+i, 10+i)
+		fmt.Fprintf(&diskstats, "   8 %7d %s %s\n   8 %7d %s1 %s\n", 16*i, device, stat, 16*i+1, device, stat)
+
+		statPath := filepath.Join(root, "sys", "class", "block", device, "stat")
+		if err := os.MkdirAll(filepath.Dir(statPath), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(statPath, []byte(stat+"\n"), 0644); err != nil {
+			b.Fatal(err)
+		}
+		ledDir := filepath.Join(root, "sys", "class", "leds", fmt.Sprintf("disk%d", i+1))
+		if err := os.MkdirAll(ledDir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		m.disks[device] = &diskState{led: m.newLED(fmt.Sprintf("disk%d", i+1)), device: device, look: m.baseLook()}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "proc", "diskstats"), []byte(diskstats.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return m
+}
+
+// BenchmarkStatFiles reads the stat file of each of eight bays into a new
+// string, as checkIO did before it used /proc/diskstats
+func BenchmarkStatFiles(b *testing.B) {
+	m := buildBenchBays(b, b.TempDir())
+	lastStat := make(map[string]string)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for device := range m.disks {
+			data, err := os.ReadFile(m.sysPath("class/block", device, "stat"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if stat := string(data); stat != lastStat[device] {
+				lastStat[device] = stat
+			}
+		}
+	}
+}
+
+// BenchmarkCheckIO samples eight idle bays from /proc/diskstats
+func BenchmarkCheckIO(b *testing.B) {
+	m := buildBenchBays(b, b.TempDir())
+	m.checkIO()
+	defer m.diskstats.close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.checkIO()
+	}
+}
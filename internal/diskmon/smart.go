@@ -0,0 +1,129 @@
+package diskmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// smartReport holds the parts of smartctl -j -a output that decide a disk's
+// health
+type smartReport struct {
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	ATASmartAttributes struct {
+		Table []smartAttribute `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// smartAttribute is an entry of the ATA SMART attribute table
+type smartAttribute struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Value      int    `json:"value"`
+	Thresh     int    `json:"thresh"`
+	WhenFailed string `json:"when_failed"`
+	Raw        struct {
+		Value uint64 `json:"value"`
+	} `json:"raw"`
+}
+
+// smartResult is the outcome of a SMART check
+type smartResult struct {
+	skipped  bool           // the disk was in standby or reported nothing
+	failures []string       // reasons the disk is failing
+	warnings []string       // reasons the disk may be failing soon
+	deltas   map[int]string // attributes that rose too fast since the last check
+	raw      map[int]uint64
+}
+
+// smartStatus checks the SMART health of a disk. prevRaw holds the raw
+// attribute values of the previous check, for the delta thresholds.
+func (m *Monitor) smartStatus(ctx context.Context, device string, prevRaw map[int]uint64) (smartResult, error) {
+	if isNVMe(device) {
+		// NVMe health comes from the SMART / health information log
+		reason, err := m.checkNVMeHealth(ctx, device)
+		if err != nil {
+			return smartResult{}, err
+		}
+		var result smartResult
+		if reason != "" {
+			result.failures = []string{reason}
+		}
+		return result, nil
+	}
+
+	// Don't spin up disks in standby to read their attributes
+	var output []byte
+	var err error
+	m.ownCommand(device, func() {
+		output, err = m.runner().Output(ctx, "smartctl", "-j", "-a", "-n", "standby,0", "/dev/"+device)
+	})
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return smartResult{}, err
+	}
+
+	var report smartReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return smartResult{}, fmt.Errorf("failed to parse smartctl output: %w", err)
+	}
+	return m.evaluateSMART(&report, prevRaw), nil
+}
+
+// evaluateSMART applies the overall SMART status and the configured
+// attribute thresholds to a report
+func (m *Monitor) evaluateSMART(report *smartReport, prevRaw map[int]uint64) smartResult {
+	result := smartResult{raw: make(map[int]uint64), deltas: make(map[int]string)}
+	attributes := report.ATASmartAttributes.Table
+	if report.SmartStatus == nil && len(attributes) == 0 {
+		result.skipped = true
+		return result
+	}
+
+	if report.SmartStatus != nil && !report.SmartStatus.Passed {
+		result.failures = append(result.failures, "overall health self-assessment failed")
+	}
+
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].ID < attributes[j].ID })
+	for _, a := range attributes {
+		raw := a.Raw.Value
+		result.raw[a.ID] = raw
+		name := fmt.Sprintf("%d %s", a.ID, a.Name)
+
+		if a.WhenFailed == "now" {
+			result.failures = append(result.failures, fmt.Sprintf("%s normalized value %d at or below threshold %d", name, a.Value, a.Thresh))
+		}
+		if limit, ok := m.cfg.SmartFailThresholds[a.ID]; ok && raw >= limit {
+			result.failures = append(result.failures, fmt.Sprintf("%s raw value %d reached failure threshold %d", name, raw, limit))
+		} else if limit, ok := m.cfg.SmartWarnThresholds[a.ID]; ok && raw >= limit {
+			result.warnings = append(result.warnings, fmt.Sprintf("%s raw value %d reached warning threshold %d", name, raw, limit))
+		}
+
+		prev, seen := prevRaw[a.ID]
+		if limit, ok := m.cfg.SmartDeltaThresholds[a.ID]; ok && seen && limit > 0 && raw >= prev+limit {
+			result.deltas[a.ID] = fmt.Sprintf("%s rose from %d to %d", name, prev, raw)
+		}
+	}
+	return result
+}
+
+// smartReason joins the reasons of a SMART result for logging, followed by
+// the delta reasons in attribute order
+func smartReason(reasons []string, deltas map[int]string) string {
+	all := append([]string(nil), reasons...)
+	ids := make([]int, 0, len(deltas))
+	for id := range deltas {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		all = append(all, deltas[id])
+	}
+	return strings.Join(all, ", ")
+}
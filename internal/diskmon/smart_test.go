@@ -0,0 +1,123 @@
+package diskmon
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+const smartctlReport = `{
+  "smart_status": {"passed": true},
+  "ata_smart_attributes": {
+    "table": [
+      {"id": 197, "name": "Current_Pending_Sector", "value": 100, "thresh": 0, "when_failed": "", "raw": {"value": 3}},
+      {"id": 5, "name": "Reallocated_Sector_Ct", "value": 100, "thresh": 10, "when_failed": "", "raw": {"value": 120}},
+      {"id": 199, "name": "UDMA_CRC_Error_Count", "value": 200, "thresh": 0, "when_failed": "", "raw": {"value": 40}},
+      {"id": 3, "name": "Spin_Up_Time", "value": 1, "thresh": 21, "when_failed": "now", "raw": {"value": 9000}}
+    ]
+  }
+}`
+
+func TestMonitor_EvaluateSMART(t *testing.T) {
+	m := &Monitor{cfg: &config.DiskMonitorConfig{
+		SmartWarnThresholds:  map[int]uint64{5: 1, 197: 1, 199: 100},
+		SmartFailThresholds:  map[int]uint64{5: 100},
+		SmartDeltaThresholds: map[int]uint64{199: 10},
+	}}
+	var report smartReport
+	if err := json.Unmarshal([]byte(smartctlReport), &report); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+
+	result := m.evaluateSMART(&report, map[int]uint64{199: 25})
+	if result.skipped {
+		t.Fatal("evaluateSMART() skipped a report with attributes")
+	}
+	expectedFailures := []string{
+		"3 Spin_Up_Time normalized value 1 at or below threshold 21",
+		"5 Reallocated_Sector_Ct raw value 120 reached failure threshold 100",
+	}
+	if !reflect.DeepEqual(result.failures, expectedFailures) {
+		t.Errorf("failures = %q, want %q", result.failures, expectedFailures)
+	}
+	// Attribute 5 fails, so it does not warn as well
+	expectedWarnings := []string{"197 Current_Pending_Sector raw value 3 reached warning threshold 1"}
+	if !reflect.DeepEqual(result.warnings, expectedWarnings) {
+		t.Errorf("warnings = %q, want %q", result.warnings, expectedWarnings)
+	}
+	expectedDeltas := map[int]string{199: "199 UDMA_CRC_Error_Count rose from 25 to 40"}
+	if !reflect.DeepEqual(result.deltas, expectedDeltas) {
+		t.Errorf("deltas = %q, want %q", result.deltas, expectedDeltas)
+	}
+	if result.raw[5] != 120 || result.raw[199] != 40 {
+		t.Errorf("raw = %v, want 5:120 and 199:40", result.raw)
+	}
+
+	// Without a previous check there is nothing to compare against
+	if result := m.evaluateSMART(&report, nil); len(result.deltas) != 0 {
+		t.Errorf("deltas without previous values = %q, want none", result.deltas)
+	}
+}
+
+func TestMonitor_EvaluateSMART_Status(t *testing.T) {
+	m := &Monitor{cfg: &config.DiskMonitorConfig{}}
+
+	// A disk in standby makes smartctl print no health information
+	if result := m.evaluateSMART(&smartReport{}, nil); !result.skipped {
+		t.Error("evaluateSMART() of an empty report should be skipped")
+	}
+
+	var report smartReport
+	if err := json.Unmarshal([]byte(`{"smart_status": {"passed": false}}`), &report); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+	result := m.evaluateSMART(&report, nil)
+	if len(result.failures) != 1 || len(result.warnings) != 0 {
+		t.Errorf("evaluateSMART() failures = %q, warnings = %q, want one failure", result.failures, result.warnings)
+	}
+}
+
+func TestSmartReason(t *testing.T) {
+	result := smartReason([]string{"a"}, map[int]string{199: "c", 5: "b"})
+	if result != "a, b, c" {
+		t.Errorf("smartReason() = %q, want %q", result, "a, b, c")
+	}
+}
+
+func TestMonitor_CheckSMART(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.SmartFailThresholds = map[int]uint64{5: 100}
+	cfg.ColorSmartFail = config.RGB{R: 255, G: 128, B: 0}
+	cfg.ColorCommandTimeout = config.RGB{R: 128, G: 128, B: 128}
+	m := newTestMonitor(t, root, cfg)
+	fake := &command.Fake{}
+	// smartctl sets bits of its exit status for failing attributes
+	fake.Script("smartctl -j -a -n standby,0 /dev/sda", command.Result{Output: smartctlReport, Err: &exec.ExitError{}})
+	fake.Script("smartctl -j -a -n standby,0 /dev/sdb", command.Result{Err: command.ErrTimeout})
+	m.commands = fake
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkSMART(context.Background())
+
+	if state := m.disks["sda"]; state.shown != displaySmartFail {
+		t.Errorf("sda shown = %v, want %v", state.shown, displaySmartFail)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 128 0" {
+		t.Errorf("disk1 color = %q, want %q", color, "255 128 0")
+	}
+	if state := m.disks["sdb"]; state.shown != displayTimeout {
+		t.Errorf("sdb shown = %v, want %v", state.shown, displayTimeout)
+	}
+	if color := readLED(t, root, "disk2", "color"); color != "128 128 128" {
+		t.Errorf("disk2 color = %q, want %q", color, "128 128 128")
+	}
+}
@@ -0,0 +1,190 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	sgIO          = 0x2285 // SG_IO ioctl
+	sgDxferNone   = -1
+	ataCheckPower = 0xe5 // ATA CHECK POWER MODE
+
+	// CHECK POWER MODE count register values
+	powerModeStandby = 0x00
+	powerModeIdle    = 0x80
+	powerModeActive  = 0xff
+)
+
+// sgIOHdr mirrors struct sg_io_hdr from <scsi/sg.h>. Its buffers are
+// unsafe.Pointer rather than uintptr so that the garbage collector and stack
+// copying keep track of them until the ioctl.
+type sgIOHdr struct {
+	interfaceID    int32
+	dxferDirection int32
+	cmdLen         uint8
+	mxSbLen        uint8
+	iovecCount     uint16
+	dxferLen       uint32
+	dxferp         unsafe.Pointer
+	cmdp           unsafe.Pointer
+	sbp            unsafe.Pointer
+	timeout        uint32
+	flags          uint32
+	packID         int32
+	usrPtr         unsafe.Pointer
+	status         uint8
+	maskedStatus   uint8
+	msgStatus      uint8
+	sbLenWr        uint8
+	hostStatus     uint16
+	driverStatus   uint16
+	resid          int32
+	duration       uint32
+	info           uint32
+}
+
+func (m *Monitor) standbyCheckLoop(ctx context.Context) {
+	interval := m.cfg.StandbyCheckInterval
+	if interval <= 0 {
+		interval = 1 // Default to 1 second if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkStandby(ctx)
+		}
+	}
+}
+
+func (m *Monitor) checkStandby(ctx context.Context) {
+	for _, state := range m.snapshotDisks() {
+		state.mu.RLock()
+		offline := state.offline
+		device := state.device
+		wasStandby := state.standby
+		state.mu.RUnlock()
+
+		if offline || isNVMe(device) {
+			continue
+		}
+
+		standby, err := m.diskInStandby(ctx, device)
+		if err != nil || standby == wasStandby {
+			continue
+		}
+
+		m.update(state, func(s *diskState) {
+			s.standby = standby
+		})
+
+		if standby {
+			log.Printf("Disk /dev/%s entered standby at %s", device, m.clock().Now().Format("2006-01-02 15:04:05"))
+		} else {
+			log.Printf("Disk /dev/%s left standby at %s", device, m.clock().Now().Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+// diskInStandby reports whether a disk is spun down, without waking it. It
+// asks the drive directly and falls back to the configured helper.
+func (m *Monitor) diskInStandby(ctx context.Context, device string) (standby bool, err error) {
+	m.ownCommand(device, func() {
+		standby, err = m.powerMode(ctx, device)
+	})
+	return standby, err
+}
+
+// powerMode asks a disk or the helper whether the disk is in standby
+func (m *Monitor) powerMode(ctx context.Context, device string) (bool, error) {
+	mode, err := checkPowerMode(m.devPath(device))
+	if err == nil {
+		return mode == powerModeStandby, nil
+	}
+
+	if m.cfg.StandbyMonPath == "" {
+		return false, err
+	}
+	// The helper is expected to report the drive state like `hdparm -C`
+	output, helperErr := m.runner().Output(ctx, m.cfg.StandbyMonPath, "/dev/"+device)
+	if helperErr != nil {
+		return false, fmt.Errorf("%v, helper: %w", err, helperErr)
+	}
+	return parseStandbyOutput(string(output)), nil
+}
+
+// parseStandbyOutput reports whether helper output such as
+// " drive state is:  standby" describes a spun down drive
+func parseStandbyOutput(output string) bool {
+	output = strings.ToLower(output)
+	return strings.Contains(output, "standby") || strings.Contains(output, "sleeping")
+}
+
+// checkPowerMode issues ATA CHECK POWER MODE through an ATA PASS-THROUGH(16)
+// SCSI command and returns the count register. Unlike most commands, it
+// does not spin up a drive in standby.
+func checkPowerMode(devPath string) (byte, error) {
+	f, err := os.OpenFile(devPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fd := f.Fd()
+
+	cdb := [16]byte{
+		0:  0x85,   // ATA PASS-THROUGH(16)
+		1:  3 << 1, // protocol: non-data
+		2:  0x20,   // CK_COND: return the ATA registers in the sense data
+		14: ataCheckPower,
+	}
+	var sense [32]byte
+
+	hdr := sgIOHdr{
+		interfaceID:    'S',
+		dxferDirection: sgDxferNone,
+		cmdLen:         uint8(len(cdb)),
+		mxSbLen:        uint8(len(sense)),
+		cmdp:           unsafe.Pointer(&cdb[0]),
+		sbp:            unsafe.Pointer(&sense[0]),
+		timeout:        5000,
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, sgIO, uintptr(unsafe.Pointer(&hdr)))
+	if errno != 0 {
+		return 0, fmt.Errorf("SG_IO on %s failed: %w", devPath, errno)
+	}
+
+	return parseATAReturn(sense[:hdr.sbLenWr])
+}
+
+// parseATAReturn extracts the count register from the ATA Return descriptor
+// of descriptor-format sense data
+func parseATAReturn(sense []byte) (byte, error) {
+	if len(sense) < 8 || sense[0]&0x7f != 0x72 {
+		return 0, fmt.Errorf("no descriptor sense data")
+	}
+
+	desc := sense[8:]
+	for len(desc) >= 2 {
+		length := int(desc[1]) + 2
+		if length > len(desc) {
+			break
+		}
+		if desc[0] == 0x09 && length >= 14 {
+			return desc[5], nil
+		}
+		desc = desc[length:]
+	}
+	return 0, fmt.Errorf("no ATA Return descriptor in sense data")
+}
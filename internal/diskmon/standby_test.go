@@ -0,0 +1,107 @@
+package diskmon
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func TestParseATAReturn(t *testing.T) {
+	ataReturn := func(count byte) []byte {
+		return []byte{
+			0x72, 0x01, 0x00, 0x1d, 0x00, 0x00, 0x00, 0x0e,
+			0x09, 0x0c, 0x00, 0x00, 0x00, count, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x40, 0x50,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		sense    []byte
+		expected byte
+		wantErr  bool
+	}{
+		{name: "standby", sense: ataReturn(powerModeStandby), expected: powerModeStandby},
+		{name: "idle", sense: ataReturn(powerModeIdle), expected: powerModeIdle},
+		{name: "active", sense: ataReturn(powerModeActive), expected: powerModeActive},
+		{
+			name: "skips other descriptors",
+			sense: append([]byte{0x72, 0x01, 0x00, 0x1d, 0x00, 0x00, 0x00, 0x1a,
+				0x00, 0x0a, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ataReturn(powerModeIdle)[8:]...),
+			expected: powerModeIdle,
+		},
+		{name: "fixed format", sense: []byte{0x70, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x0a}, wantErr: true},
+		{name: "empty", sense: nil, wantErr: true},
+		{name: "truncated descriptor", sense: ataReturn(powerModeIdle)[:12], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, err := parseATAReturn(tt.sense)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseATAReturn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && mode != tt.expected {
+				t.Errorf("parseATAReturn() = 0x%02x, want 0x%02x", mode, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseStandbyOutput(t *testing.T) {
+	tests := map[string]bool{
+		"\n/dev/sda:\n drive state is:  standby\n":     true,
+		"\n/dev/sda:\n drive state is:  active/idle\n": false,
+		"\n/dev/sda:\n drive state is:  sleeping\n":    true,
+		"\n/dev/sda:\n drive state is:  unknown\n":     false,
+		"": false,
+		"\n/dev/sda:\n drive state is:  IDLE_A (standby)\n": true,
+	}
+	for output, expected := range tests {
+		if result := parseStandbyOutput(output); result != expected {
+			t.Errorf("parseStandbyOutput(%q) = %v, want %v", output, result, expected)
+		}
+	}
+}
+
+func TestMonitor_CheckStandby_Helper(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+
+	// The fake device nodes are regular files, so SG_IO fails and the
+	// helper is used instead
+	writeFile(t, filepath.Join(root, "dev", "sda"), "")
+
+	cfg := hotplugConfig()
+	cfg.ColorDiskStandby = config.RGB{R: 0, G: 0, B: 255}
+	cfg.StandbyMonPath = "/usr/bin/ugreen-check-standby"
+	m := newTestMonitor(t, root, cfg)
+	fake := &command.Fake{}
+	fake.Script("/usr/bin/ugreen-check-standby /dev/sda", command.Result{Output: "\n/dev/sda:\n drive state is:  standby\n"})
+	m.commands = fake
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkStandby(context.Background())
+	if !m.disks["sda"].standby {
+		t.Fatal("sda should be in standby")
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "0 0 255" {
+		t.Errorf("disk1 color = %q, want %q", color, "0 0 255")
+	}
+
+	// I/O wakes the disk and restores the health color
+	writeDiskstats(t, root, "sda", "1 0 8 0 0 0 0 0 0 1 1")
+	m.checkIO()
+	if m.disks["sda"].standby {
+		t.Error("sda should leave standby on I/O")
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 255 255" {
+		t.Errorf("disk1 color = %q, want %q", color, "255 255 255")
+	}
+}
@@ -0,0 +1,290 @@
+package diskmon
+
+import (
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// displayState is what a disk LED shows. It is derived from all conditions
+// active on the disk, in order of precedence.
+type displayState int
+
+const (
+	displayHealthy displayState = iota
+	displayStandby
+	displayWasFaulted
+	displayPoolDegraded
+	displayVdevDegraded
+	displayScrub
+	displayResilver
+	displayZpoolErrors
+	displayTimeout
+	displaySlow
+	displayIOWarning
+	displaySmartWarning
+	displayTempCritical
+	displayZpoolFault
+	displayIOFault
+	displaySmartFail
+	displayOffline
+	displayIdentify
+)
+
+func (d displayState) String() string {
+	switch d {
+	case displayHealthy:
+		return "healthy"
+	case displayStandby:
+		return "standby"
+	case displayWasFaulted:
+		return "was faulted"
+	case displayPoolDegraded:
+		return "pool degraded"
+	case displayVdevDegraded:
+		return "vdev degraded"
+	case displayScrub:
+		return "scrub"
+	case displayResilver:
+		return "resilver"
+	case displayZpoolErrors:
+		return "zpool errors"
+	case displayTimeout:
+		return "check timed out"
+	case displaySlow:
+		return "slow"
+	case displayIOWarning:
+		return "I/O errors"
+	case displaySmartWarning:
+		return "SMART warning"
+	case displayTempCritical:
+		return "critical temperature"
+	case displayZpoolFault:
+		return "zpool fault"
+	case displayIOFault:
+		return "I/O failure"
+	case displaySmartFail:
+		return "SMART failure"
+	case displayOffline:
+		return "offline"
+	case displayIdentify:
+		return "identify"
+	}
+	return "unknown"
+}
+
+// isFault reports whether the display state hides I/O activity
+func (d displayState) isFault() bool {
+	return d >= displayZpoolFault
+}
+
+// isZpoolFault reports whether a vdev state reported by zpool status means
+// the device has failed
+func isZpoolFault(state string) bool {
+	switch state {
+	case "OFFLINE", "FAULTED", "UNAVAIL", "REMOVED", "CORRUPT":
+		return true
+	}
+	return false
+}
+
+// display derives the displayed state from the disk's conditions. The
+// caller must hold s.mu.
+func (s *diskState) display() displayState {
+	switch {
+	case !s.identifyUntil.IsZero():
+		return displayIdentify
+	case s.offline:
+		return displayOffline
+	case s.smartFailed:
+		return displaySmartFail
+	case s.ioFault != "":
+		return displayIOFault
+	}
+	d := max(s.zpool.display(), s.md.display(), s.btrfs.display(), s.latched)
+	if len(s.timedOut) > 0 {
+		d = max(d, displayTimeout)
+	}
+	if s.slow != "" {
+		d = max(d, displaySlow)
+	}
+	if s.ioWarning != "" {
+		d = max(d, displayIOWarning)
+	}
+	if s.smartWarning != "" {
+		d = max(d, displaySmartWarning)
+	}
+	if s.tempCritical {
+		d = max(d, displayTempCritical)
+	}
+	if d != displayHealthy {
+		return d
+	}
+	if s.wasFaulted != displayHealthy {
+		return displayWasFaulted
+	}
+	if s.standby {
+		return displayStandby
+	}
+	return displayHealthy
+}
+
+// color returns the LED color for a display state
+func (m *Monitor) color(d displayState) config.RGB {
+	switch d {
+	case displayStandby:
+		return m.cfg.ColorDiskStandby
+	case displayPoolDegraded:
+		return m.cfg.ColorZpoolPoolDegraded
+	case displayVdevDegraded:
+		return m.cfg.ColorZpoolVdevDegraded
+	case displayScrub:
+		return m.cfg.ColorZpoolScrub
+	case displayResilver:
+		return m.cfg.ColorZpoolResilver
+	case displayZpoolErrors:
+		return m.cfg.ColorZpoolErrors
+	case displayTimeout:
+		return m.cfg.ColorCommandTimeout
+	case displaySlow:
+		return m.cfg.ColorDiskSlow
+	case displayIOWarning:
+		return m.cfg.ColorIOErrorWarn
+	case displaySmartWarning:
+		return m.cfg.ColorSmartWarn
+	case displayTempCritical:
+		return m.cfg.ColorTemperatureCrit
+	case displayZpoolFault:
+		return m.cfg.ColorZpoolFail
+	case displayIOFault:
+		return m.cfg.ColorIOErrorFail
+	case displaySmartFail:
+		return m.cfg.ColorSmartFail
+	case displayOffline:
+		return m.cfg.ColorDiskUnavail
+	case displayIdentify:
+		return m.cfg.ColorIdentify
+	}
+	return m.cfg.ColorDiskHealth
+}
+
+// scanProgress returns the progress of the scrub, resilver or md sync the
+// disk is showing. The caller must hold s.mu.
+func (s *diskState) scanProgress() float64 {
+	if s.md.display() > s.zpool.display() {
+		return s.md.progress
+	}
+	return s.zpool.scan.Progress
+}
+
+// ledLook is what a disk LED is set to
+type ledLook struct {
+	color      config.RGB
+	brightness int
+	blink      int // timer trigger period in milliseconds, 0 for I/O activity
+	shot       int // oneshot blink length in milliseconds
+}
+
+// baseLook is the look setupDiskLED gives a disk LED
+func (m *Monitor) baseLook() ledLook {
+	return ledLook{color: m.cfg.ColorDiskHealth, brightness: m.cfg.BrightnessDiskLeds, shot: 100}
+}
+
+// look returns the LED look for the disk's current conditions. The caller
+// must hold s.mu.
+func (m *Monitor) look(s *diskState) ledLook {
+	l := ledLook{color: m.color(s.shown), brightness: m.cfg.BrightnessDiskLeds, shot: 100}
+	if m.cfg.SeparateReadWrite {
+		switch {
+		case s.activity != ioIdle:
+			l.shot = m.activityShot(s.activity)
+		case s.look.shot > 0:
+			// An idle disk keeps the blink length of its last I/O
+			l.shot = s.look.shot
+		}
+	}
+	switch s.shown {
+	case displayHealthy:
+		l.color = m.idleColor(s)
+		if m.cfg.SeparateReadWrite {
+			l.color = m.activityColor(s.activity, l.color)
+		}
+	case displayScrub, displayResilver:
+		l.blink = m.cfg.ZpoolScanBlinkInterval
+		if l.blink <= 0 {
+			l.blink = 1000 // Default to 1 second if invalid
+		}
+		l.brightness = scanBrightness(m.cfg.BrightnessDiskLeds, s.scanProgress())
+	case displayWasFaulted:
+		l.color = m.color(s.wasFaulted)
+		l.brightness = m.cfg.LatchedBrightness
+		if l.brightness <= 0 {
+			l.brightness = m.cfg.BrightnessDiskLeds / 4 // Default to a quarter brightness if invalid
+		}
+	case displayTempCritical:
+		l.blink = m.cfg.TemperatureCritBlinkInterval
+		if l.blink <= 0 {
+			l.blink = 250 // Default to 250 milliseconds if invalid
+		}
+	case displayIdentify:
+		l.blink = m.cfg.IdentifyBlinkInterval
+		if l.blink <= 0 {
+			l.blink = 100 // Default to 100 milliseconds if invalid
+		}
+	}
+	if l.blink == 0 {
+		m.scaleLook(&l, s.load)
+	}
+	return l
+}
+
+// update applies a transition to the disk's conditions and refreshes its LED
+// if its look changed. It returns the new display state.
+func (m *Monitor) update(state *diskState, transition func(s *diskState)) displayState {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	transition(state)
+	state.shown = state.display()
+
+	prev, next := state.look, m.look(state)
+	if next == prev {
+		return state.shown
+	}
+	state.look = next
+
+	l := state.led
+	retrigger := next.blink != prev.blink
+	if retrigger {
+		if next.blink > 0 {
+			l.SetTrigger("timer")
+			l.SetDelayOn(next.blink)
+			l.SetDelayOff(next.blink)
+		} else {
+			l.SetTrigger("oneshot")
+			l.SetInvert(1)
+			l.SetDelayOn(next.shot)
+			l.SetDelayOff(next.shot)
+		}
+	} else if next.blink == 0 && next.shot != prev.shot {
+		l.SetDelayOn(next.shot)
+		l.SetDelayOff(next.shot)
+	}
+	if retrigger || next.color != prev.color {
+		l.SetColor(next.color.R, next.color.G, next.color.B)
+	}
+	if retrigger || next.brightness != prev.brightness {
+		l.SetBrightness(next.brightness)
+	}
+	return state.shown
+}
+
+// snapshotDisks returns the states of all mapped disks
+func (m *Monitor) snapshotDisks() []*diskState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	disks := make([]*diskState, 0, len(m.disks))
+	for _, state := range m.disks {
+		disks = append(disks, state)
+	}
+	return disks
+}
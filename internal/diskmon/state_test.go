@@ -0,0 +1,105 @@
+package diskmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func TestDiskState_Display(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    *diskState
+		expected displayState
+	}{
+		{name: "healthy", state: &diskState{}, expected: displayHealthy},
+		{name: "healthy pool member", state: &diskState{zpool: zpoolMember{state: "ONLINE"}}, expected: displayHealthy},
+		{name: "standby", state: &diskState{standby: true, zpool: zpoolMember{state: "ONLINE"}}, expected: displayStandby},
+		{name: "pool degraded over standby", state: &diskState{standby: true, zpool: zpoolMember{state: "ONLINE", poolState: "DEGRADED"}}, expected: displayPoolDegraded},
+		{name: "degraded disk", state: &diskState{zpool: zpoolMember{state: "DEGRADED", vdevState: "ONLINE", poolState: "DEGRADED"}}, expected: displayVdevDegraded},
+		{name: "zpool errors over degraded vdev", state: &diskState{zpool: zpoolMember{state: "ONLINE", vdevState: "DEGRADED", errors: true}}, expected: displayZpoolErrors},
+		{name: "smart warning over zpool errors", state: &diskState{smartWarning: "5 Reallocated_Sector_Ct", zpool: zpoolMember{state: "ONLINE", errors: true}}, expected: displaySmartWarning},
+		{name: "zpool fault over smart warning", state: &diskState{smartWarning: "5 Reallocated_Sector_Ct", zpool: zpoolMember{state: "FAULTED"}}, expected: displayZpoolFault},
+		{name: "zpool fault over standby", state: &diskState{standby: true, zpool: zpoolMember{state: "FAULTED"}}, expected: displayZpoolFault},
+		{name: "smart over zpool", state: &diskState{smartFailed: true, zpool: zpoolMember{state: "UNAVAIL"}}, expected: displaySmartFail},
+		{name: "offline over everything", state: &diskState{offline: true, smartFailed: true, zpool: zpoolMember{state: "REMOVED"}, standby: true}, expected: displayOffline},
+		{name: "latched smart failure", state: &diskState{latched: displaySmartFail, zpool: zpoolMember{state: "ONLINE"}}, expected: displaySmartFail},
+		{name: "timed out over degraded pool", state: &diskState{timedOut: []string{"zpool"}, zpool: zpoolMember{state: "ONLINE", poolState: "DEGRADED"}}, expected: displayTimeout},
+		{name: "zpool fault over timed out", state: &diskState{timedOut: []string{"smart"}, zpool: zpoolMember{state: "FAULTED"}}, expected: displayZpoolFault},
+		{name: "was faulted", state: &diskState{wasFaulted: displayZpoolFault, standby: true}, expected: displayWasFaulted},
+		{name: "pool degraded over was faulted", state: &diskState{wasFaulted: displayZpoolFault, zpool: zpoolMember{state: "ONLINE", poolState: "DEGRADED"}}, expected: displayPoolDegraded},
+		{name: "identify over offline", state: &diskState{offline: true, identifyUntil: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}, expected: displayIdentify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.state.display(); result != tt.expected {
+				t.Errorf("display() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMonitor_Update(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.ColorSmartFail = config.RGB{R: 255, G: 128, B: 0}
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 128}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+
+	steps := []struct {
+		name       string
+		transition func(s *diskState)
+		expected   displayState
+		color      string
+	}{
+		{name: "zpool fault", transition: func(s *diskState) { s.zpool.state = "FAULTED" }, expected: displayZpoolFault, color: "255 0 128"},
+		{name: "smart failure", transition: func(s *diskState) { s.smartFailed = true }, expected: displaySmartFail, color: "255 128 0"},
+		{name: "zpool recovers", transition: func(s *diskState) { s.zpool.state = "ONLINE" }, expected: displaySmartFail, color: "255 128 0"},
+		{name: "smart recovers", transition: func(s *diskState) { s.smartFailed = false }, expected: displayHealthy, color: "255 255 255"},
+	}
+	for _, step := range steps {
+		if result := m.update(state, step.transition); result != step.expected {
+			t.Errorf("%s: update() = %v, want %v", step.name, result, step.expected)
+		}
+		if color := readLED(t, root, "disk1", "color"); color != step.color {
+			t.Errorf("%s: disk1 color = %q, want %q", step.name, color, step.color)
+		}
+	}
+}
+
+func TestMonitor_CheckDiskOnline_Recovery(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, hotplugConfig())
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	removeFakeDisk(t, root, "sda")
+	m.checkDiskOnline()
+	if m.disks["sda"].shown != displayOffline {
+		t.Fatalf("sda shown = %v, want %v", m.disks["sda"].shown, displayOffline)
+	}
+
+	// A disk that is offline does not blink
+	writeDiskstats(t, root, "sda", "1 0 8 0 0 0 0 0 0 1 1")
+	m.checkIO()
+	if m.disks["sda"].sampled {
+		t.Error("checkIO() should skip offline disks")
+	}
+
+	buildFakeDisks(t, root, testDisks()[:1])
+	m.checkDiskOnline()
+	if m.disks["sda"].offline || m.disks["sda"].shown != displayHealthy {
+		t.Errorf("sda should be healthy once it returns, shown = %v", m.disks["sda"].shown)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 255 255" {
+		t.Errorf("disk1 color = %q, want %q", color, "255 255 255")
+	}
+}
@@ -0,0 +1,178 @@
+package diskmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func (m *Monitor) temperatureCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckTemperatureInterval
+	if interval <= 0 {
+		interval = 60 // Default to 60 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	// Show temperatures right away rather than after the first interval
+	m.checkTemperature(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkTemperature(ctx)
+		}
+	}
+}
+
+func (m *Monitor) checkTemperature(ctx context.Context) {
+	for _, state := range m.snapshotDisks() {
+		state.mu.RLock()
+		device := state.device
+		offline := state.offline
+		standby := state.standby
+		wasCritical := state.tempCritical
+		state.mu.RUnlock()
+
+		// A disk in standby keeps its last reading, it cools down anyway
+		if offline || standby {
+			continue
+		}
+
+		temp, ok, err := m.diskTemperature(ctx, device)
+		m.noteTimeout(state, "temperature", err)
+		if err != nil {
+			log.Printf("Warning: Failed to read temperature of /dev/%s: %v", device, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		critical := m.cfg.TemperatureCrit > 0 && temp >= m.cfg.TemperatureCrit
+		m.update(state, func(s *diskState) {
+			s.temperature = temp
+			s.tempCritical = critical
+		})
+
+		if critical && !wasCritical {
+			log.Printf("Disk /dev/%s reached critical temperature %d°C at %s", device, temp, m.clock().Now().Format("2006-01-02 15:04:05"))
+		} else if !critical && wasCritical {
+			log.Printf("Disk /dev/%s cooled down to %d°C at %s", device, temp, m.clock().Now().Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+// diskTemperature returns the temperature of a disk in degrees Celsius. It
+// reports false if the disk is in standby or has no sensor. Disks in standby
+// are never woken up.
+func (m *Monitor) diskTemperature(ctx context.Context, device string) (int, bool, error) {
+	awake := isNVMe(device)
+	if !awake {
+		// drivetemp queries the drive, which may spin it up
+		standby, err := m.diskInStandby(ctx, device)
+		if err == nil && standby {
+			return 0, false, nil
+		}
+		awake = err == nil
+	}
+
+	if awake {
+		if temp, ok := m.hwmonTemperature(device); ok {
+			return temp, true, nil
+		}
+	}
+
+	// smartctl checks the power mode itself before reading the drive
+	var output []byte
+	var err error
+	m.ownCommand(device, func() {
+		output, err = m.runner().Output(ctx, "smartctl", "-j", "-A", "-n", "standby,0", "/dev/"+device)
+	})
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, false, err
+	}
+	return parseSmartTemperature(output)
+}
+
+// hwmonTemperature reads the temperature of a disk from the hwmon device
+// registered by drivetemp or the NVMe driver for it
+func (m *Monitor) hwmonTemperature(device string) (int, bool) {
+	diskDev, err := filepath.EvalSymlinks(m.sysPath("block", device, "device"))
+	if err != nil {
+		return 0, false
+	}
+
+	entries, err := os.ReadDir(m.sysPath("class/hwmon"))
+	if err != nil {
+		return 0, false
+	}
+	for _, entry := range entries {
+		hwmonDir := m.sysPath("class/hwmon", entry.Name())
+		hwmonDev, err := filepath.EvalSymlinks(filepath.Join(hwmonDir, "device"))
+		if err != nil || hwmonDev != diskDev {
+			continue
+		}
+		// temp1 is the composite temperature on NVMe drives
+		data, err := os.ReadFile(filepath.Join(hwmonDir, "temp1_input"))
+		if err != nil {
+			continue
+		}
+		millidegrees, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			continue
+		}
+		return millidegrees / 1000, true
+	}
+	return 0, false
+}
+
+// parseSmartTemperature extracts the current temperature from smartctl -j
+// output. It reports false if there is none, as for a disk in standby.
+func parseSmartTemperature(output []byte) (int, bool, error) {
+	var report struct {
+		Temperature *struct {
+			Current int `json:"current"`
+		} `json:"temperature"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return 0, false, fmt.Errorf("failed to parse smartctl output: %w", err)
+	}
+	if report.Temperature == nil {
+		return 0, false, nil
+	}
+	return report.Temperature.Current, true, nil
+}
+
+// temperatureColor shifts the healthy color towards ColorTemperatureHot as
+// the temperature rises from the warning to the critical threshold
+func (m *Monitor) temperatureColor(temp int) config.RGB {
+	warn, crit := m.cfg.TemperatureWarn, m.cfg.TemperatureCrit
+	low, high := m.cfg.ColorDiskHealth, m.cfg.ColorTemperatureHot
+	if warn <= 0 || temp <= warn {
+		return low
+	}
+	if crit <= warn || temp >= crit {
+		return high
+	}
+
+	percentage := float64(temp-warn) / float64(crit-warn)
+	return config.RGB{
+		R: low.R + int(percentage*float64(high.R-low.R)),
+		G: low.G + int(percentage*float64(high.G-low.G)),
+		B: low.B + int(percentage*float64(high.B-low.B)),
+	}
+}
@@ -0,0 +1,107 @@
+package diskmon
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+// buildFakeHwmon registers an hwmon sensor for the device directory of a disk
+func buildFakeHwmon(t *testing.T, root, name, device, millidegrees string) {
+	t.Helper()
+	devDir, err := filepath.EvalSymlinks(filepath.Join(root, "sys", "block", device, "device"))
+	if err != nil {
+		t.Fatalf("Failed to resolve device of %s: %v", device, err)
+	}
+	hwmonDir := filepath.Join(devDir, "hwmon", name)
+	writeFile(t, filepath.Join(hwmonDir, "temp1_input"), millidegrees+"\n")
+	symlink(t, devDir, filepath.Join(hwmonDir, "device"))
+	symlink(t, hwmonDir, filepath.Join(root, "sys", "class", "hwmon", name))
+}
+
+func TestParseSmartTemperature(t *testing.T) {
+	temp, ok, err := parseSmartTemperature([]byte(`{"temperature": {"current": 41}}`))
+	if err != nil || !ok || temp != 41 {
+		t.Errorf("parseSmartTemperature() = %d, %v, %v, want 41, true, nil", temp, ok, err)
+	}
+
+	// smartctl -n standby prints no temperature for a disk in standby
+	if _, ok, err := parseSmartTemperature([]byte(`{"power_mode": "STANDBY"}`)); ok || err != nil {
+		t.Errorf("parseSmartTemperature() of a disk in standby = %v, %v, want false, nil", ok, err)
+	}
+	if _, _, err := parseSmartTemperature([]byte("not json")); err == nil {
+		t.Error("parseSmartTemperature() of invalid output should fail")
+	}
+}
+
+func TestMonitor_TemperatureColor(t *testing.T) {
+	m := &Monitor{cfg: &config.DiskMonitorConfig{
+		TemperatureWarn:     40,
+		TemperatureCrit:     50,
+		ColorDiskHealth:     config.RGB{R: 255, G: 255, B: 255},
+		ColorTemperatureHot: config.RGB{R: 255, G: 0, B: 0},
+	}}
+
+	tests := map[int]config.RGB{
+		0:  {R: 255, G: 255, B: 255},
+		40: {R: 255, G: 255, B: 255},
+		45: {R: 255, G: 128, B: 128},
+		50: {R: 255, G: 0, B: 0},
+		70: {R: 255, G: 0, B: 0},
+	}
+	for temp, expected := range tests {
+		if result := m.temperatureColor(temp); result != expected {
+			t.Errorf("temperatureColor(%d) = %v, want %v", temp, result, expected)
+		}
+	}
+}
+
+func TestMonitor_CheckTemperature(t *testing.T) {
+	root := t.TempDir()
+	buildFakeNVMe(t, root, "0000:01:00.0", "nvme0", "nvme0n1")
+	ctrlDir := filepath.Join(root, "sys", "devices", "pci0000:00", "0000:00:1d.0", "0000:01:00.0", "nvme", "nvme0")
+	symlink(t, ctrlDir, filepath.Join(ctrlDir, "nvme0n1", "device"))
+	buildFakeHwmon(t, root, "hwmon3", "nvme0n1", "47850")
+
+	cfg := hotplugConfig()
+	cfg.MappingMethod = "nvme"
+	cfg.TemperatureWarn = 40
+	cfg.TemperatureCrit = 50
+	cfg.ColorTemperatureHot = config.RGB{R: 255, G: 0, B: 0}
+	cfg.ColorTemperatureCrit = config.RGB{R: 255, G: 0, B: 255}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkTemperature(context.Background())
+	state := m.disks["nvme0n1"]
+	if state.temperature != 47 || state.shown != displayHealthy {
+		t.Errorf("nvme0n1 temperature = %d, shown = %v, want 47, %v", state.temperature, state.shown, displayHealthy)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "255 77 77" {
+		t.Errorf("disk1 color = %q, want %q", color, "255 77 77")
+	}
+
+	// Above the critical temperature the disk blinks
+	buildFakeHwmon(t, root, "hwmon3", "nvme0n1", "52000")
+	m.checkTemperature(context.Background())
+	if state.shown != displayTempCritical {
+		t.Errorf("nvme0n1 shown = %v, want %v", state.shown, displayTempCritical)
+	}
+	for attr, value := range map[string]string{"color": "255 0 255", "trigger": "timer", "delay_on": "250"} {
+		if result := readLED(t, root, "disk1", attr); result != value {
+			t.Errorf("disk1 %s = %q, want %q", attr, result, value)
+		}
+	}
+
+	// Disks in standby are not read
+	m.update(state, func(s *diskState) { s.standby = true })
+	buildFakeHwmon(t, root, "hwmon3", "nvme0n1", "30000")
+	m.checkTemperature(context.Background())
+	if state.temperature != 52 {
+		t.Errorf("nvme0n1 temperature = %d in standby, want %d", state.temperature, 52)
+	}
+}
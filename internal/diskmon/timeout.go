@@ -0,0 +1,84 @@
+package diskmon
+
+import (
+	"log"
+	"slices"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+)
+
+// hostCommands runs external tools for monitors without a runner of their own
+var hostCommands = &command.Exec{}
+
+// runner returns the runner of external tools
+func (m *Monitor) runner() command.Runner {
+	if m.commands == nil {
+		return hostCommands
+	}
+	return m.commands
+}
+
+// setTimedOut records whether the last run of a check for the disk timed
+// out. It reports whether that changed.
+func (m *Monitor) setTimedOut(state *diskState, check string, timedOut bool) bool {
+	state.mu.RLock()
+	was := slices.Contains(state.timedOut, check)
+	state.mu.RUnlock()
+	if was == timedOut {
+		return false
+	}
+
+	m.update(state, func(s *diskState) {
+		if timedOut {
+			s.timedOut = append(s.timedOut, check)
+		} else {
+			s.timedOut = slices.DeleteFunc(s.timedOut, func(c string) bool { return c == check })
+		}
+	})
+	return true
+}
+
+// noteTimeout records the outcome of a check of one disk and logs when the
+// check starts or stops timing out
+func (m *Monitor) noteTimeout(state *diskState, check string, err error) {
+	timedOut := command.IsTimeout(err)
+	if !m.setTimedOut(state, check, timedOut) {
+		return
+	}
+
+	state.mu.RLock()
+	device := state.device
+	state.mu.RUnlock()
+	now := m.clock().Now().Format("2006-01-02 15:04:05")
+	if timedOut {
+		log.Printf("Warning: %s check of /dev/%s timed out at %s", check, device, now)
+	} else {
+		log.Printf("%s check of /dev/%s completed again at %s", check, device, now)
+	}
+}
+
+// noteMembersTimeout records the outcome of a check covering several disks,
+// such as zpool status. When it times out, the health of the disks member
+// reports as belonging to it is unknown.
+func (m *Monitor) noteMembersTimeout(check string, err error, member func(s *diskState) bool) {
+	timedOut := command.IsTimeout(err)
+	changed := false
+	for _, state := range m.snapshotDisks() {
+		state.mu.RLock()
+		affected := timedOut && member(state)
+		state.mu.RUnlock()
+		if m.setTimedOut(state, check, affected) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	now := m.clock().Now().Format("2006-01-02 15:04:05")
+	if timedOut {
+		log.Printf("Warning: %s check timed out at %s: %v", check, now, err)
+	} else {
+		log.Printf("%s check completed again at %s", check, now)
+	}
+}
@@ -0,0 +1,67 @@
+package diskmon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+)
+
+func TestMonitor_NoteTimeout(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	cfg := hotplugConfig()
+	cfg.ColorCommandTimeout = config.RGB{R: 128, G: 128, B: 128}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	state := m.disks["sda"]
+	timeout := &command.Error{Name: "smartctl", Err: command.ErrTimeout}
+
+	m.noteTimeout(state, "smart", timeout)
+	if state.shown != displayTimeout {
+		t.Errorf("sda shown = %v after a timeout, want %v", state.shown, displayTimeout)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "128 128 128" {
+		t.Errorf("disk1 color = %q, want %q", color, "128 128 128")
+	}
+
+	// Another check completing leaves the timeout showing
+	m.noteTimeout(state, "temperature", nil)
+	if state.shown != displayTimeout {
+		t.Errorf("sda shown = %v after another check, want %v", state.shown, displayTimeout)
+	}
+
+	// A tool that fails rather than hangs is not a timeout
+	m.noteTimeout(state, "smart", &command.Error{Name: "smartctl", Err: errors.New("exit status 2")})
+	if state.shown != displayHealthy {
+		t.Errorf("sda shown = %v after the check failed, want %v", state.shown, displayHealthy)
+	}
+}
+
+func TestMonitor_NoteMembersTimeout(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, testDisks())
+	m := newTestMonitor(t, root, hotplugConfig())
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+	member := m.disks["sdb"]
+	m.update(member, func(s *diskState) { s.zpool = zpoolMember{pool: "tank", state: "ONLINE"} })
+	isMember := func(s *diskState) bool { return s.zpool.pool != "" }
+
+	m.noteMembersTimeout("zpool", &command.Error{Name: "zpool", Err: command.ErrTimeout}, isMember)
+	if member.shown != displayTimeout {
+		t.Errorf("sdb shown = %v after zpool timed out, want %v", member.shown, displayTimeout)
+	}
+	if state := m.disks["sda"]; state.shown != displayHealthy {
+		t.Errorf("sda shown = %v, want %v outside the pool", state.shown, displayHealthy)
+	}
+
+	m.noteMembersTimeout("zpool", nil, isMember)
+	if member.shown != displayHealthy {
+		t.Errorf("sdb shown = %v after zpool completed, want %v", member.shown, displayHealthy)
+	}
+}
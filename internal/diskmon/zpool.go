@@ -0,0 +1,254 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/zpool"
+)
+
+// zpoolMember is the pool membership of a disk, as shown on its LED
+type zpoolMember struct {
+	pool      string
+	poolState string
+	vdevState string
+	device    string // device reported by zpool status, relative to /dev
+	state     string
+	read      uint64
+	write     uint64
+	cksum     uint64
+	errors    bool       // an error count reached the configured threshold
+	scan      zpool.Scan // scrub or resilver touching this disk
+}
+
+// display returns the display state for the disk's pool membership
+func (z zpoolMember) display() displayState {
+	switch {
+	case isZpoolFault(z.state):
+		return displayZpoolFault
+	case z.errors:
+		return displayZpoolErrors
+	case z.scan.Active && z.scan.Function == "resilver":
+		return displayResilver
+	case z.scan.Active && z.scan.Function == "scrub":
+		return displayScrub
+	case z.vdevState == "DEGRADED" || z.state == "DEGRADED":
+		// A degraded leaf, e.g. a disk ZFS stopped trusting after too
+		// many errors, degrades its vdev like a degraded mirror
+		return displayVdevDegraded
+	case z.poolState == "DEGRADED":
+		return displayPoolDegraded
+	}
+	return displayHealthy
+}
+
+func (m *Monitor) buildZpoolMapping(ctx context.Context) error {
+	vdevs, err := zpool.Status(ctx, m.runner(), m.root)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range vdevs {
+		ledNames := m.diskLEDs(v.Disks)
+		if len(ledNames) > 0 {
+			m.mu.Lock()
+			m.zpoolLEDMap[v.Name] = ledNames[0]
+			m.mu.Unlock()
+			if m.cfg.DebugZpool {
+				log.Printf("zpool device %s -> %v -> LED: %v", v.Name, v.Disks, ledNames)
+			}
+		}
+	}
+
+	return nil
+}
+
+// zpoolLEDs finds the LEDs of a vdev, by its disks or else by the name it
+// had when the mapping was built
+func (m *Monitor) zpoolLEDs(v zpool.Vdev) []string {
+	if ledNames := m.diskLEDs(v.Disks); len(ledNames) > 0 {
+		return ledNames
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if ledName, ok := m.zpoolLEDMap[v.Name]; ok {
+		return []string{ledName}
+	}
+	return nil
+}
+
+func (m *Monitor) zpoolCheckLoop(ctx context.Context) {
+	interval := m.cfg.CheckZpoolInterval
+	if interval <= 0 {
+		interval = 5 // Default to 5 seconds if invalid
+	}
+	ticker := m.clock().NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.checkZpool(ctx)
+		}
+	}
+}
+
+func (m *Monitor) checkZpool(ctx context.Context) {
+	vdevs, err := zpool.Status(ctx, m.runner(), m.root)
+	// Pool members keep their last known state while zpool hangs
+	m.noteMembersTimeout("zpool", err, func(s *diskState) bool { return s.zpool.pool != "" })
+	if err != nil {
+		return
+	}
+
+	m.applyZpoolStatus(vdevs)
+}
+
+// applyZpoolStatus updates the pool membership of every mapped disk
+func (m *Monitor) applyZpoolStatus(vdevs []zpool.Vdev) {
+	threshold := uint64(m.cfg.ZpoolErrorThreshold)
+	if threshold == 0 {
+		threshold = 1
+	}
+
+	m.logZpoolScans(vdevs)
+
+	// A resilver shows on the devices being resilvered, or on the whole
+	// pool when zpool status does not say which ones they are
+	resilverMarked := make(map[string]bool)
+	for _, v := range vdevs {
+		if v.Resilvering {
+			resilverMarked[v.Pool] = true
+		}
+	}
+
+	// A disk with several vdevs (partitions) shows the worst of them
+	members := make(map[string]zpoolMember)
+	for _, v := range vdevs {
+		ledNames := m.zpoolLEDs(v)
+		if len(ledNames) == 0 {
+			if m.cfg.DebugZpool {
+				log.Printf("WARNING: ZPOOL device %s not found in LED mapping", v.Name)
+			}
+			continue
+		}
+
+		member := zpoolMember{
+			pool:      v.Pool,
+			poolState: v.PoolState,
+			vdevState: v.ParentState,
+			device:    strings.TrimPrefix(v.Path, "/dev/"),
+			state:     v.State,
+			read:      v.Read,
+			write:     v.Write,
+			cksum:     v.Cksum,
+			errors:    v.Read >= threshold || v.Write >= threshold || v.Cksum >= threshold,
+		}
+		switch {
+		case v.State == "AVAIL":
+			// Idle spares take no part in a scan
+		case v.Scan.Function == "resilver" && resilverMarked[v.Pool] && !v.Resilvering:
+		default:
+			member.scan = v.Scan
+		}
+		for _, ledName := range ledNames {
+			if prev, seen := members[ledName]; !seen || member.display() > prev.display() {
+				members[ledName] = member
+			}
+		}
+	}
+
+	m.mu.RLock()
+	disks := make(map[string]*diskState, len(m.ledToDevice))
+	for ledName, device := range m.ledToDevice {
+		disks[ledName] = m.disks[device]
+	}
+	m.mu.RUnlock()
+
+	for ledName, state := range disks {
+		// Disks missing from the output are not (or no longer) pool members
+		member := members[ledName]
+
+		state.mu.RLock()
+		prev := state.zpool
+		state.mu.RUnlock()
+		if prev == member {
+			continue
+		}
+
+		m.update(state, func(s *diskState) {
+			s.zpool = member
+		})
+
+		level, prevLevel := member.display(), prev.display()
+		if level == prevLevel {
+			continue
+		}
+
+		now := m.clock().Now().Format("2006-01-02 15:04:05")
+		switch {
+		case level == displayZpoolFault:
+			if m.cfg.DebugZpool {
+				log.Printf("ZPOOL Disk failure detected on /dev/%s (state: %s) -> LED: %s at %s", member.device, member.state, ledName, now)
+			} else {
+				log.Printf("ZPOOL Disk failure detected on /dev/%s (state: %s) at %s", member.device, member.state, now)
+			}
+			m.recordEvent(state, "zpool", fmt.Sprintf("%s in pool %s", member.state, member.pool))
+		case level != displayHealthy:
+			log.Printf("ZPOOL warning on /dev/%s: %s (pool %s %s, vdev %s, state %s, errors %d/%d/%d) at %s",
+				member.device, level, member.pool, member.poolState, member.vdevState, member.state, member.read, member.write, member.cksum, now)
+			if level == displayZpoolErrors {
+				m.recordEvent(state, "zpool-errors", fmt.Sprintf("state %s, errors %d/%d/%d in pool %s", member.state, member.read, member.write, member.cksum, member.pool))
+			}
+		case prevLevel == displayZpoolFault:
+			log.Printf("ZPOOL Disk /dev/%s recovered (state: %s) at %s", state.device, member.state, now)
+			m.recordEvent(state, "zpool-recovered", member.state)
+		default:
+			log.Printf("ZPOOL warning on /dev/%s cleared at %s", state.device, now)
+		}
+	}
+}
+
+// logZpoolScans logs scrubs and resilvers starting and finishing. It is
+// only called from the zpool check loop.
+func (m *Monitor) logZpoolScans(vdevs []zpool.Vdev) {
+	if m.zpoolScans == nil {
+		m.zpoolScans = make(map[string]zpool.Scan)
+	}
+
+	now := m.clock().Now().Format("2006-01-02 15:04:05")
+	seen := make(map[string]bool)
+	for _, v := range vdevs {
+		if seen[v.Pool] {
+			continue
+		}
+		seen[v.Pool] = true
+
+		scan, prev := v.Scan, m.zpoolScans[v.Pool]
+		m.zpoolScans[v.Pool] = scan
+		switch {
+		case scan.Active && !prev.Active:
+			log.Printf("ZPOOL %s started on pool %s at %s", scan.Function, v.Pool, now)
+		case !scan.Active && prev.Active:
+			log.Printf("ZPOOL %s finished on pool %s with %d errors (%s) at %s", prev.Function, v.Pool, scan.Errors, scan.Summary, now)
+		}
+	}
+}
+
+// scanBrightness scales the LED brightness with scan progress, from a
+// quarter of full brightness at the start up to full brightness
+func scanBrightness(brightness int, progress float64) int {
+	if progress < 0 {
+		progress = 0
+	}
+	if progress > 1 {
+		progress = 1
+	}
+	return int(float64(brightness) * (0.25 + 0.75*progress))
+}
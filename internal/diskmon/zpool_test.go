@@ -0,0 +1,321 @@
+package diskmon
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/zpool"
+)
+
+const zpoolStatusDegraded = `  pool: tank
+ state: DEGRADED
+status: One or more devices are faulted in response to persistent errors.
+	Sufficient replicas exist for the pool to continue functioning in a
+	degraded state.
+action: Replace the faulted device, or use 'zpool clear' to mark the device
+	repaired.
+  scan: scrub repaired 0B in 01:02:03 with 0 errors on Sun Oct 11 01:26:04 2026
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        DEGRADED     0     0     0
+	  raidz1-0  DEGRADED     0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     FAULTED      3   1.2K    0  too many errors
+	    sdc     ONLINE       0     0     2
+	  mirror-1  ONLINE       0     0     0
+	    sdd1    ONLINE       0     0     0
+	    sdd9    ONLINE       0     0     0
+	logs
+	  sde       ONLINE       0     0     0
+	spares
+	  sdf       AVAIL
+
+errors: No known data errors
+
+  pool: backup
+ state: ONLINE
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	backup      ONLINE       0     0     0
+	  sdg       ONLINE       0     0     0
+
+errors: No known data errors
+`
+
+// parseZpool parses zpool status text and resolves its vdevs below root
+func parseZpool(root, output string) []zpool.Vdev {
+	vdevs := zpool.ParseText(output)
+	zpool.Resolve(root, vdevs)
+	return vdevs
+}
+
+func TestMonitor_ApplyZpoolStatus(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0"},
+		{name: "sdc", hctl: "2:0:0:0"},
+		{name: "sdd", hctl: "3:0:0:0"},
+	})
+	cfg := hotplugConfig()
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 0}
+	cfg.ColorZpoolPoolDegraded = config.RGB{R: 255, G: 255, B: 0}
+	cfg.ColorZpoolVdevDegraded = config.RGB{R: 255, G: 128, B: 0}
+	cfg.ColorZpoolErrors = config.RGB{R: 255, G: 64, B: 0}
+	cfg.ZpoolErrorThreshold = 2
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.applyZpoolStatus(parseZpool(root, zpoolStatusDegraded))
+
+	expected := map[string]struct {
+		shown displayState
+		color string
+	}{
+		"sda": {displayVdevDegraded, "255 128 0"},
+		"sdb": {displayZpoolFault, "255 0 0"},
+		"sdc": {displayZpoolErrors, "255 64 0"},
+		"sdd": {displayPoolDegraded, "255 255 0"},
+	}
+	for device, want := range expected {
+		state := m.disks[device]
+		if state.shown != want.shown {
+			t.Errorf("%s shown = %v, want %v", device, state.shown, want.shown)
+		}
+		if color := readLED(t, root, m.deviceToLED[device], "color"); color != want.color {
+			t.Errorf("%s color = %q, want %q", device, color, want.color)
+		}
+	}
+
+	// Once the pool is healthy again every disk recovers
+	m.applyZpoolStatus(parseZpool(root, `  pool: tank
+ state: ONLINE
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  raidz1-0  ONLINE       0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     ONLINE       0     0     0
+	    sdc     ONLINE       0     0     0
+	  sdd       ONLINE       0     0     0
+`))
+	for device := range expected {
+		if state := m.disks[device]; state.shown != displayHealthy {
+			t.Errorf("%s shown = %v after recovery, want %v", device, state.shown, displayHealthy)
+		}
+	}
+}
+
+const zpoolStatusResilver = `  pool: tank
+ state: DEGRADED
+status: One or more devices is currently being resilvered.
+action: Wait for the resilver to complete.
+  scan: resilver in progress since Sun Oct 18 10:00:00 2026
+	1.20T scanned at 1.00G/s, 600G issued at 500M/s, 2.40T total
+	150G resilvered, 25.00% done, 01:00:00 to go
+config:
+
+	NAME             STATE     READ WRITE CKSUM
+	tank             DEGRADED     0     0     0
+	  mirror-0       DEGRADED     0     0     0
+	    sda          ONLINE       0     0     0
+	    replacing-1  DEGRADED     0     0     0
+	      sdx        UNAVAIL      0     0     0
+	      sdb        ONLINE       0     0     0  (resilvering)
+
+errors: No known data errors
+
+  pool: backup
+ state: ONLINE
+  scan: scrub in progress since Sun Oct 18 09:00:00 2026
+	1.00T scanned at 1.00G/s, 500G issued at 500M/s, 1.00T total
+	0B repaired, 50.00% done, 00:20:00 to go
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	backup      ONLINE       0     0     0
+	  sdc       ONLINE       0     0     0
+
+errors: No known data errors
+`
+
+func TestScanBrightness(t *testing.T) {
+	tests := map[float64]int{0: 50, 0.5: 125, 1: 200, 2: 200}
+	for progress, expected := range tests {
+		if result := scanBrightness(200, progress); result != expected {
+			t.Errorf("scanBrightness(200, %v) = %d, want %d", progress, result, expected)
+		}
+	}
+}
+
+func TestMonitor_ApplyZpoolStatus_Scan(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0"},
+		{name: "sdc", hctl: "2:0:0:0"},
+	})
+	cfg := hotplugConfig()
+	cfg.ColorZpoolPoolDegraded = config.RGB{R: 255, G: 255, B: 0}
+	cfg.ColorZpoolVdevDegraded = config.RGB{R: 255, G: 128, B: 0}
+	cfg.ColorZpoolScrub = config.RGB{R: 0, G: 255, B: 255}
+	cfg.ColorZpoolResilver = config.RGB{R: 255, G: 0, B: 255}
+	cfg.ZpoolScanBlinkInterval = 500
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.applyZpoolStatus(parseZpool(root, zpoolStatusResilver))
+
+	expected := map[string]struct {
+		shown      displayState
+		color      string
+		trigger    string
+		brightness string
+	}{
+		// sda is not being resilvered, so it shows the degraded mirror
+		"sda": {displayVdevDegraded, "255 128 0", "oneshot", "255"},
+		"sdb": {displayResilver, "255 0 255", "timer", "111"},
+		"sdc": {displayScrub, "0 255 255", "timer", "159"},
+	}
+	for device, want := range expected {
+		led := m.deviceToLED[device]
+		if state := m.disks[device]; state.shown != want.shown {
+			t.Errorf("%s shown = %v, want %v", device, state.shown, want.shown)
+		}
+		for attr, value := range map[string]string{"color": want.color, "trigger": want.trigger, "brightness": want.brightness} {
+			if result := readLED(t, root, led, attr); result != value {
+				t.Errorf("%s %s = %q, want %q", device, attr, result, value)
+			}
+		}
+	}
+	if delay := readLED(t, root, m.deviceToLED["sdb"], "delay_on"); delay != "500" {
+		t.Errorf("sdb delay_on = %q, want %q", delay, "500")
+	}
+
+	// When the scrub finishes the disk goes back to the I/O trigger
+	m.applyZpoolStatus(parseZpool(root, `  pool: backup
+ state: ONLINE
+  scan: scrub repaired 0B in 00:40:00 with 0 errors on Sun Oct 18 09:40:00 2026
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	backup      ONLINE       0     0     0
+	  sdc       ONLINE       0     0     0
+`))
+	if state := m.disks["sdc"]; state.shown != displayHealthy {
+		t.Errorf("sdc shown = %v after scrub, want %v", state.shown, displayHealthy)
+	}
+	if trigger := readLED(t, root, m.deviceToLED["sdc"], "trigger"); trigger != "oneshot" {
+		t.Errorf("sdc trigger = %q after scrub, want %q", trigger, "oneshot")
+	}
+}
+
+func TestMonitor_ApplyZpoolStatus_Encrypted(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0"},
+	})
+	// LUKS on the first partition of each disk, as zpool status -L shows it
+	for i, disk := range []string{"sda", "sdb"} {
+		dm := fmt.Sprintf("dm-%d", 3+i)
+		writeFile(t, filepath.Join(root, "sys", "block", disk, disk+"1", "partition"), "1\n")
+		symlink(t, "../../"+disk+"1", filepath.Join(root, "sys", "block", dm, "slaves", disk+"1"))
+	}
+
+	cfg := hotplugConfig()
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 0}
+	cfg.ColorZpoolVdevDegraded = config.RGB{R: 255, G: 128, B: 0}
+	m := newTestMonitor(t, root, cfg)
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.applyZpoolStatus(parseZpool(root, `  pool: vault
+ state: DEGRADED
+config:
+
+	NAME           STATE     READ WRITE CKSUM
+	vault          DEGRADED     0     0     0
+	  mirror-0     DEGRADED     0     0     0
+	    /dev/dm-3  ONLINE       0     0     0
+	    /dev/dm-4  FAULTED      0    14     0  too many errors
+`))
+
+	if state := m.disks["sda"]; state.shown != displayVdevDegraded {
+		t.Errorf("sda shown = %v, want %v", state.shown, displayVdevDegraded)
+	}
+	if state := m.disks["sdb"]; state.shown != displayZpoolFault {
+		t.Errorf("sdb shown = %v, want %v", state.shown, displayZpoolFault)
+	}
+	if color := readLED(t, root, m.deviceToLED["sdb"], "color"); color != "255 0 0" {
+		t.Errorf("sdb color = %q, want %q", color, "255 0 0")
+	}
+}
+
+func TestMonitor_CheckZpool(t *testing.T) {
+	root := t.TempDir()
+	buildFakeDisks(t, root, []fakeDisk{
+		{name: "sda", hctl: "0:0:0:0"},
+		{name: "sdb", hctl: "1:0:0:0"},
+	})
+	cfg := hotplugConfig()
+	cfg.ColorZpoolFail = config.RGB{R: 255, G: 0, B: 0}
+	cfg.ColorCommandTimeout = config.RGB{R: 128, G: 128, B: 128}
+	m := newTestMonitor(t, root, cfg)
+	fake := &command.Fake{}
+	// zpool before OpenZFS 2.3 rejects -j, so the text output is parsed
+	unsupported := command.Result{Stderr: "invalid option 'j'", Err: &exec.ExitError{}}
+	fake.Script("zpool status -j --json-int -P -L", unsupported, command.Result{Err: command.ErrTimeout}, unsupported)
+	fake.Script("zpool status -P -L", command.Result{Output: zpoolStatusDegraded}, command.Result{Output: `  pool: tank
+ state: ONLINE
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  raidz1-0  ONLINE       0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     ONLINE       0     0     0
+`})
+	m.commands = fake
+	if err := m.initializeDisks(); err != nil {
+		t.Fatalf("initializeDisks() error = %v", err)
+	}
+
+	m.checkZpool(context.Background())
+	if state := m.disks["sdb"]; state.shown != displayZpoolFault {
+		t.Errorf("sdb shown = %v, want %v", state.shown, displayZpoolFault)
+	}
+
+	// While zpool hangs, pool members keep their last state and show that
+	// their health is unknown
+	m.checkZpool(context.Background())
+	if state := m.disks["sda"]; state.shown != displayTimeout {
+		t.Errorf("sda shown = %v while zpool hangs, want %v", state.shown, displayTimeout)
+	}
+	if color := readLED(t, root, "disk1", "color"); color != "128 128 128" {
+		t.Errorf("disk1 color = %q while zpool hangs, want %q", color, "128 128 128")
+	}
+	if state := m.disks["sdb"]; state.shown != displayZpoolFault {
+		t.Errorf("sdb shown = %v while zpool hangs, want %v", state.shown, displayZpoolFault)
+	}
+
+	m.checkZpool(context.Background())
+	for _, device := range []string{"sda", "sdb"} {
+		if state := m.disks[device]; state.shown != displayHealthy {
+			t.Errorf("%s shown = %v after the pool recovered, want %v", device, state.shown, displayHealthy)
+		}
+	}
+}
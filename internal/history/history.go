@@ -0,0 +1,200 @@
+// Package history keeps a per-disk record of faults in a JSON file, so that
+// faults outlive the service and stay latched until an operator acknowledges
+// them. Disks are keyed by serial number, which follows them between bays
+// and across renames of their kernel device.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxEvents is how many events are kept per disk, oldest first out
+const maxEvents = 100
+
+// Event is something that happened to a disk
+type Event struct {
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"` // kernel name at the time, e.g. sda
+	Kind   string    `json:"kind"`   // e.g. "smart", "zpool", "offline", "online", "acknowledged"
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Fault is a latched fault of a disk
+type Fault struct {
+	Kind   string    `json:"kind"`
+	Since  time.Time `json:"since"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Disk is the stored history of one disk
+type Disk struct {
+	Latched      []Fault   `json:"latched,omitempty"`
+	Acknowledged time.Time `json:"acknowledged,omitempty"` // when latched faults were last acknowledged
+	Events       []Event   `json:"events,omitempty"`
+}
+
+// Store is the fault history of all disks ever seen, saved to a file after
+// every change
+type Store struct {
+	path  string
+	mu    sync.Mutex
+	disks map[string]*Disk // serial -> history
+}
+
+// Open loads the store saved at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, disks: make(map[string]*Disk)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.disks); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if s.disks == nil {
+		s.disks = make(map[string]*Disk)
+	}
+	return s, nil
+}
+
+// Record adds an event to a disk's history. If latch is set, the event's
+// kind also becomes a latched fault, unless one is latched already.
+func (s *Store) Record(serial string, e Event, latch bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.disk(serial)
+	d.Events = append(d.Events, e)
+	if n := len(d.Events); n > maxEvents {
+		d.Events = append(d.Events[:0], d.Events[n-maxEvents:]...)
+	}
+	if latch && !hasFault(d.Latched, e.Kind) {
+		d.Latched = append(d.Latched, Fault{Kind: e.Kind, Since: e.Time, Detail: e.Detail})
+	}
+	return s.save()
+}
+
+// Acknowledge removes the latched faults of a disk and records when they
+// were acknowledged. It reports whether any fault was latched.
+func (s *Store) Acknowledge(serial, device string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.disks[serial]
+	if !ok || len(d.Latched) == 0 {
+		return false, nil
+	}
+	d.Latched = nil
+	d.Acknowledged = at
+	d.Events = append(d.Events, Event{Time: at, Device: device, Kind: "acknowledged"})
+	return true, s.save()
+}
+
+// Acknowledged returns when the latched faults of a disk were last
+// acknowledged, zero if never
+func (s *Store) Acknowledged(serial string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.disks[serial]; ok {
+		return d.Acknowledged
+	}
+	return time.Time{}
+}
+
+// Latched returns the latched faults of a disk
+func (s *Store) Latched(serial string) []Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.disks[serial]; ok {
+		return append([]Fault(nil), d.Latched...)
+	}
+	return nil
+}
+
+// Events returns the history of a disk, oldest first
+func (s *Store) Events(serial string) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.disks[serial]; ok {
+		return append([]Event(nil), d.Events...)
+	}
+	return nil
+}
+
+// disk returns the history of a disk, creating it if needed. The caller
+// must hold s.mu.
+func (s *Store) disk(serial string) *Disk {
+	d, ok := s.disks[serial]
+	if !ok {
+		d = &Disk{}
+		s.disks[serial] = d
+	}
+	return d
+}
+
+// save writes the store to a temporary file, syncs it and renames it over
+// the old one, so that a crash or power loss never leaves a truncated file.
+// The caller must hold s.mu.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.disks, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	// The rename itself is only durable once the directory is synced
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writeSynced writes a file and flushes it to disk
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func hasFault(faults []Fault, kind string) bool {
+	for _, f := range faults {
+		if f.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
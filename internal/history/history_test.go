@@ -0,0 +1,111 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore_RecordAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib", "state.json")
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() of a missing file error = %v", err)
+	}
+	failure := Event{Time: at, Device: "sda", Kind: "smart", Detail: "5 Reallocated_Sector_Ct"}
+	if err := s.Record("WD-WCC7K1234567", failure, true); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	// A second failure of the same kind keeps the first latched
+	if err := s.Record("WD-WCC7K1234567", Event{Time: at.Add(time.Hour), Device: "sda", Kind: "smart"}, true); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := s.Record("WD-WCC7K1234567", Event{Time: at.Add(2 * time.Hour), Device: "sda", Kind: "offline"}, false); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	expected := []Fault{{Kind: "smart", Since: at, Detail: "5 Reallocated_Sector_Ct"}}
+	if latched := s.Latched("WD-WCC7K1234567"); !reflect.DeepEqual(latched, expected) {
+		t.Errorf("Latched() = %+v, want %+v", latched, expected)
+	}
+	if events := s.Events("WD-WCC7K1234567"); len(events) != 3 || events[2].Kind != "offline" {
+		t.Errorf("Events() = %+v, want 3 events ending with offline", events)
+	}
+	if latched := s.Latched("ZL2ABCDE"); latched != nil {
+		t.Errorf("Latched() of an unknown disk = %+v, want none", latched)
+	}
+}
+
+func TestStore_Acknowledge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := s.Record("ZL2ABCDE", Event{Time: at, Device: "sdb", Kind: "zpool", Detail: "FAULTED"}, true); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	acked, err := s.Acknowledge("ZL2ABCDE", "sdb", at.Add(time.Minute))
+	if err != nil || !acked {
+		t.Fatalf("Acknowledge() = %v, %v, want true", acked, err)
+	}
+	if acked, _ := s.Acknowledge("ZL2ABCDE", "sdb", at.Add(2*time.Minute)); acked {
+		t.Error("Acknowledge() without latched faults should report false")
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if latched := s.Latched("ZL2ABCDE"); len(latched) != 0 {
+		t.Errorf("Latched() after Acknowledge() = %+v, want none", latched)
+	}
+	if acked := s.Acknowledged("ZL2ABCDE"); !acked.Equal(at.Add(time.Minute)) {
+		t.Errorf("Acknowledged() = %v, want %v", acked, at.Add(time.Minute))
+	}
+	expected := Event{Time: at.Add(time.Minute), Device: "sdb", Kind: "acknowledged"}
+	if events := s.Events("ZL2ABCDE"); len(events) != 2 || events[1] != expected {
+		t.Errorf("Events() = %+v, want the fault and %+v", events, expected)
+	}
+}
+
+func TestStore_MaxEvents(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < maxEvents+5; i++ {
+		if err := s.Record("ZL2ABCDE", Event{Time: at.Add(time.Duration(i) * time.Second), Kind: "offline"}, false); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	events := s.Events("ZL2ABCDE")
+	if len(events) != maxEvents {
+		t.Fatalf("len(Events()) = %d, want %d", len(events), maxEvents)
+	}
+	if !events[0].Time.Equal(at.Add(5 * time.Second)) {
+		t.Errorf("oldest event at %v, want %v", events[0].Time, at.Add(5*time.Second))
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Open() of an invalid file should fail")
+	}
+}
@@ -17,9 +17,14 @@ type LED struct {
 
 // NewLED creates a new LED controller for the given LED name
 func NewLED(name string) *LED {
+	return NewLEDAt(sysfsLEDPath, name)
+}
+
+// NewLEDAt creates a new LED controller for an LED class directory under base
+func NewLEDAt(base, name string) *LED {
 	return &LED{
 		name: name,
-		path: filepath.Join(sysfsLEDPath, name),
+		path: filepath.Join(base, name),
 	}
 }
 
@@ -5,33 +5,99 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/clock"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/config"
 	"github.com/scottjab/nix-ugreen-leds-controller/internal/led"
 )
 
+// ledName is the LED that shows network activity
+const ledName = "netdev"
+
+// gatewayState is the outcome of a gateway check
+type gatewayState int
+
+const (
+	gatewayReachable gatewayState = iota
+	gatewayUnreachable
+	gatewayTimeout // ip or ping hung, so whether the gateway answers is unknown
+)
+
+// monitor shows the state of a network interface on the netdev LED
+type monitor struct {
+	cfg      *config.NetworkMonitorConfig
+	iface    string
+	root     string         // filesystem root for /sys, empty for the host
+	commands command.Runner // runs ip and ping
+	clk      clock.Clock    // drives the check loop, the system clock if nil
+	led      *led.LED
+	timedOut bool // the last gateway check timed out
+}
+
 func Run(ctx context.Context, cfg *config.NetworkMonitorConfig, interfaceName string) error {
 	// Check if we need to do anything
 	if !cfg.CheckGatewayConnectivity && !cfg.CheckLinkSpeed && !cfg.CheckLinkSpeedDynamic {
 		return nil
 	}
 
-	ledName := "netdev"
-	l := led.NewLED(ledName)
-	if !l.Exists() {
+	m := newMonitor(cfg, interfaceName, cfg.Root, command.NewExec(cfg.CommandTimeouts))
+	if !m.led.Exists() {
 		return fmt.Errorf("LED %s does not exist", ledName)
 	}
+	if err := m.setupLED(); err != nil {
+		return err
+	}
+
+	m.run(ctx)
+	return nil
+}
+
+func newMonitor(cfg *config.NetworkMonitorConfig, interfaceName, root string, commands command.Runner) *monitor {
+	m := &monitor{cfg: cfg, iface: interfaceName, root: root, commands: commands}
+	m.led = led.NewLEDAt(m.sysPath("class/leds"), ledName)
+	return m
+}
+
+// clock returns the monitor's clock, the system clock if none is set
+func (m *monitor) clock() clock.Clock {
+	if m.clk == nil {
+		return clock.Real
+	}
+	return m.clk
+}
 
-	// Initialize LED for netdev trigger
+// run checks the interface every check interval until ctx is done
+func (m *monitor) run(ctx context.Context) {
+	ticker := m.clock().NewTicker(time.Duration(m.cfg.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.check(ctx)
+		}
+	}
+}
+
+// sysPath returns a path below /sys, relative to the monitor's filesystem root
+func (m *monitor) sysPath(elem ...string) string {
+	return filepath.Join(append([]string{"/", m.root, "sys"}, elem...)...)
+}
+
+// setupLED initializes the LED for the netdev trigger
+func (m *monitor) setupLED() error {
+	l, cfg := m.led, m.cfg
 	if err := l.SetTrigger("netdev"); err != nil {
 		return fmt.Errorf("failed to set netdev trigger: %w", err)
 	}
-	if err := l.SetDeviceName(interfaceName); err != nil {
+	if err := l.SetDeviceName(m.iface); err != nil {
 		return fmt.Errorf("failed to set device name: %w", err)
 	}
 	if err := l.SetLink(1); err != nil {
@@ -52,44 +118,59 @@ func Run(ctx context.Context, cfg *config.NetworkMonitorConfig, interfaceName st
 	if err := l.SetBrightness(cfg.BrightnessLed); err != nil {
 		return fmt.Errorf("failed to set brightness: %w", err)
 	}
+	return nil
+}
 
-	ticker := time.NewTicker(time.Duration(cfg.CheckInterval) * time.Second)
-	defer ticker.Stop()
+// check sets the LED color from gateway connectivity and link speed
+func (m *monitor) check(ctx context.Context) {
+	cfg := m.cfg
+	gwState := gatewayReachable
 
-	gwConn := true
+	// Check gateway connectivity if enabled
+	if cfg.CheckGatewayConnectivity {
+		gw, err := m.getGateway(ctx)
+		switch {
+		case command.IsTimeout(err):
+			gwState = gatewayTimeout
+		case err != nil:
+			log.Printf("Failed to get gateway: %v", err)
+			gwState = gatewayUnreachable
+		default:
+			gwState = m.pingGateway(ctx, gw)
+		}
+		m.noteTimeout(gwState == gatewayTimeout)
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			// Check gateway connectivity if enabled
-			if cfg.CheckGatewayConnectivity {
-				gw, err := getGateway()
-				if err != nil {
-					log.Printf("Failed to get gateway: %v", err)
-					gwConn = false
-				} else {
-					gwConn = pingGateway(gw)
-				}
-			}
+	// Set color based on state
+	switch gwState {
+	case gatewayUnreachable:
+		m.led.SetColor(cfg.ColorGatewayUnreachable.R, cfg.ColorGatewayUnreachable.G, cfg.ColorGatewayUnreachable.B)
+	case gatewayTimeout:
+		m.led.SetColor(cfg.ColorCommandTimeout.R, cfg.ColorCommandTimeout.G, cfg.ColorCommandTimeout.B)
+	default:
+		// Set normal color based on link speed
+		color := m.getNormalColor()
+		m.led.SetColor(color.R, color.G, color.B)
+	}
+}
 
-			// Set color based on state
-			if !gwConn {
-				// Gateway unreachable
-				l.SetColor(cfg.ColorGatewayUnreachable.R, cfg.ColorGatewayUnreachable.G, cfg.ColorGatewayUnreachable.B)
-			} else {
-				// Set normal color based on link speed
-				color := getNormalColor(cfg, interfaceName)
-				l.SetColor(color.R, color.G, color.B)
-			}
-		}
+// noteTimeout logs when the gateway check starts or stops timing out
+func (m *monitor) noteTimeout(timedOut bool) {
+	if timedOut == m.timedOut {
+		return
+	}
+	m.timedOut = timedOut
+
+	now := m.clock().Now().Format("2006-01-02 15:04:05")
+	if timedOut {
+		log.Printf("Warning: gateway check of %s timed out at %s", m.iface, now)
+	} else {
+		log.Printf("gateway check of %s completed again at %s", m.iface, now)
 	}
 }
 
-func getGateway() (string, error) {
-	cmd := exec.Command("ip", "route")
-	output, err := cmd.Output()
+func (m *monitor) getGateway(ctx context.Context) (string, error) {
+	output, err := m.commands.Output(ctx, "ip", "route")
 	if err != nil {
 		return "", err
 	}
@@ -109,26 +190,33 @@ func getGateway() (string, error) {
 	return "", fmt.Errorf("no default gateway found")
 }
 
-func pingGateway(gw string) bool {
-	cmd := exec.Command("ping", "-q", "-c", "1", "-W", "1", gw)
-	err := cmd.Run()
-	return err == nil
+func (m *monitor) pingGateway(ctx context.Context, gw string) gatewayState {
+	_, err := m.commands.Output(ctx, "ping", "-q", "-c", "1", "-W", "1", gw)
+	switch {
+	case command.IsTimeout(err):
+		// ping gives up on its own after a second, a hung ping is a local problem
+		return gatewayTimeout
+	case err != nil:
+		return gatewayUnreachable
+	}
+	return gatewayReachable
 }
 
-func getNormalColor(cfg *config.NetworkMonitorConfig, interfaceName string) config.RGB {
-	if cfg.CheckLinkSpeedDynamic {
-		return getDynamicColor(cfg, interfaceName)
+func (m *monitor) getNormalColor() config.RGB {
+	if m.cfg.CheckLinkSpeedDynamic {
+		return m.getDynamicColor()
 	}
 
-	if cfg.CheckLinkSpeed {
-		return getLinkSpeedColor(cfg, interfaceName)
+	if m.cfg.CheckLinkSpeed {
+		return m.getLinkSpeedColor()
 	}
 
-	return cfg.ColorNormal
+	return m.cfg.ColorNormal
 }
 
-func getDynamicColor(cfg *config.NetworkMonitorConfig, interfaceName string) config.RGB {
-	speed, err := getLinkSpeed(interfaceName)
+func (m *monitor) getDynamicColor() config.RGB {
+	cfg := m.cfg
+	speed, err := m.getLinkSpeed()
 	if err != nil {
 		return cfg.ColorNormal
 	}
@@ -158,8 +246,9 @@ func getDynamicColor(cfg *config.NetworkMonitorConfig, interfaceName string) con
 	return config.RGB{R: r, G: g, B: b}
 }
 
-func getLinkSpeedColor(cfg *config.NetworkMonitorConfig, interfaceName string) config.RGB {
-	speed, err := getLinkSpeed(interfaceName)
+func (m *monitor) getLinkSpeedColor() config.RGB {
+	cfg := m.cfg
+	speed, err := m.getLinkSpeed()
 	if err != nil {
 		return cfg.ColorNormal
 	}
@@ -206,8 +295,8 @@ func getLinkSpeedColor(cfg *config.NetworkMonitorConfig, interfaceName string) c
 	}
 }
 
-func getLinkSpeed(interfaceName string) (int, error) {
-	speedPath := filepath.Join("/sys/class/net", interfaceName, "speed")
+func (m *monitor) getLinkSpeed() (int, error) {
+	speedPath := m.sysPath("class/net", m.iface, "speed")
 	data, err := os.ReadFile(speedPath)
 	if err != nil {
 		return 0, err
@@ -220,4 +309,3 @@ func getLinkSpeed(interfaceName string) (int, error) {
 
 	return speed, nil
 }
-
@@ -0,0 +1,184 @@
+package zpool
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// count is a number that zpool status -j prints either as a JSON number
+// (with --json-int) or as a string such as "1.2K"
+type count uint64
+
+func (c *count) UnmarshalJSON(data []byte) error {
+	*c = count(parseCount(strings.Trim(string(data), `"`)))
+	return nil
+}
+
+type jsonStatus struct {
+	Pools map[string]jsonPool `json:"pools"`
+}
+
+type jsonPool struct {
+	Name      string              `json:"name"`
+	State     string              `json:"state"`
+	ScanStats *jsonScan           `json:"scan_stats"`
+	Vdevs     map[string]jsonVdev `json:"vdevs"`
+	Logs      map[string]jsonVdev `json:"logs"`
+	Special   map[string]jsonVdev `json:"special"`
+	Dedup     map[string]jsonVdev `json:"dedup"`
+	L2cache   map[string]jsonVdev `json:"l2cache"`
+	Spares    map[string]jsonVdev `json:"spares"`
+}
+
+type jsonScan struct {
+	Function  string          `json:"function"`
+	State     string          `json:"state"`
+	StartTime json.RawMessage `json:"start_time"`
+	EndTime   json.RawMessage `json:"end_time"`
+	ToExamine count           `json:"to_examine"`
+	Issued    count           `json:"issued"`
+	Errors    count           `json:"errors"`
+}
+
+type jsonVdev struct {
+	Name           string              `json:"name"`
+	VdevType       string              `json:"vdev_type"`
+	Path           string              `json:"path"`
+	State          string              `json:"state"`
+	ReadErrors     count               `json:"read_errors"`
+	WriteErrors    count               `json:"write_errors"`
+	ChecksumErrors count               `json:"checksum_errors"`
+	Vdevs          map[string]jsonVdev `json:"vdevs"`
+}
+
+// ParseJSON returns the leaf vdevs of all pools in the output of
+// zpool status -j
+func ParseJSON(data []byte) ([]Vdev, error) {
+	var status jsonStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse zpool status JSON: %w", err)
+	}
+	if status.Pools == nil {
+		return nil, fmt.Errorf("zpool status JSON has no pools object")
+	}
+
+	var vdevs []Vdev
+	for _, name := range sortedKeys(status.Pools) {
+		pool := status.Pools[name]
+		if pool.Name == "" {
+			pool.Name = name
+		}
+		scan := pool.ScanStats.scan()
+
+		// The root vdev is named after the pool and holds the normal
+		// top-level vdevs, the other classes are listed beside it
+		var top []map[string]jsonVdev
+		for _, root := range sortedKeys(pool.Vdevs) {
+			top = append(top, pool.Vdevs[root].Vdevs)
+		}
+		top = append(top, pool.Logs, pool.Special, pool.Dedup, pool.L2cache, pool.Spares)
+
+		for _, class := range top {
+			for _, key := range sortedKeys(class) {
+				for _, v := range class[key].leaves(key, nil) {
+					v.Pool = pool.Name
+					v.PoolState = pool.State
+					v.Scan = scan
+					vdevs = append(vdevs, v)
+				}
+			}
+		}
+	}
+	return vdevs, nil
+}
+
+// leaves returns the leaf vdevs below v, which is keyed by key in its parent
+func (v jsonVdev) leaves(key string, parent *jsonVdev) []Vdev {
+	if v.Name == "" {
+		v.Name = key
+	}
+	if len(v.Vdevs) > 0 {
+		var vdevs []Vdev
+		for _, child := range sortedKeys(v.Vdevs) {
+			vdevs = append(vdevs, v.Vdevs[child].leaves(child, &v)...)
+		}
+		return vdevs
+	}
+
+	leaf := Vdev{
+		Name:  v.Name,
+		Path:  v.Path,
+		State: v.State,
+		Read:  uint64(v.ReadErrors),
+		Write: uint64(v.WriteErrors),
+		Cksum: uint64(v.ChecksumErrors),
+	}
+	if leaf.Path == "" {
+		leaf.Path = v.Name
+	}
+	if parent != nil {
+		leaf.Parent = parent.Name
+		leaf.ParentState = parent.State
+		// The JSON output does not mark the devices being resilvered, but
+		// they are the healthy halves of replacing and spare vdevs
+		leaf.Resilvering = (parent.VdevType == "replacing" || parent.VdevType == "spare") && v.State == "ONLINE"
+	}
+	return []Vdev{leaf}
+}
+
+// scan converts the scan_stats of a pool, which are missing if the pool
+// was never scanned
+func (s *jsonScan) scan() Scan {
+	if s == nil {
+		return Scan{}
+	}
+
+	var scan Scan
+	switch strings.ToUpper(s.Function) {
+	case "RESILVER":
+		scan.Function = "resilver"
+	case "SCRUB", "ERRORSCRUB":
+		scan.Function = "scrub"
+	default:
+		return Scan{}
+	}
+
+	scan.Errors = int(s.Errors)
+	switch strings.ToUpper(s.State) {
+	case "SCANNING":
+		scan.Active = true
+		if s.ToExamine > 0 {
+			scan.Progress = float64(s.Issued) / float64(s.ToExamine)
+			if scan.Progress > 1 {
+				scan.Progress = 1
+			}
+		}
+		scan.Summary = scan.Function + " in progress" + jsonTime(" since ", s.StartTime)
+	case "FINISHED":
+		scan.Summary = scan.Function + " finished" + jsonTime(" on ", s.EndTime)
+	default:
+		scan.Summary = scan.Function + " " + strings.ToLower(s.State) + jsonTime(" on ", s.EndTime)
+	}
+	return scan
+}
+
+// jsonTime formats a time from zpool status -j for a summary, which is a
+// string unless --json-int turned it into a number
+func jsonTime(prefix string, raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || s == "" {
+		return ""
+	}
+	return prefix + s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,42 @@
+package zpool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	vdevs, err := ParseJSON(readFixture(t, "status.json"))
+	if err != nil {
+		t.Fatalf("ParseJSON() error = %v", err)
+	}
+
+	scrubbed := Scan{Function: "scrub", Errors: 2, Summary: "scrub finished on Sun Oct 11 01:26:04 2026"}
+	resilver := Scan{Function: "resilver", Active: true, Progress: 0.25, Summary: "resilver in progress since Sun Oct 18 10:00:00 2026"}
+	expected := []Vdev{
+		{Pool: "backup", PoolState: "ONLINE", Name: "/dev/sdg", Path: "/dev/sdg1", State: "ONLINE", Read: 1200, Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Name: "/dev/nvme0n1p3", Path: "/dev/nvme0n1p3", State: "ONLINE", Cksum: 12, Scan: resilver},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "mirror-0", ParentState: "DEGRADED", Name: "/dev/sda1", Path: "/dev/sda1", State: "ONLINE", Scan: resilver},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "replacing-1", ParentState: "DEGRADED", Name: "/dev/sdd1", Path: "/dev/sdd1", State: "ONLINE", Scan: resilver, Resilvering: true},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "replacing-1", ParentState: "DEGRADED", Name: "4839211982734645123", Path: "/dev/sdb1", State: "UNAVAIL", Scan: resilver},
+		{Pool: "tank", PoolState: "DEGRADED", Name: "/dev/nvme0n1p1", Path: "/dev/nvme0n1p1", State: "ONLINE", Scan: resilver},
+		{Pool: "tank", PoolState: "DEGRADED", Name: "/dev/sdf1", Path: "/dev/sdf1", State: "AVAIL", Scan: resilver},
+	}
+
+	if len(vdevs) != len(expected) {
+		t.Fatalf("ParseJSON() returned %d vdevs, want %d: %+v", len(vdevs), len(expected), vdevs)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(vdevs[i], expected[i]) {
+			t.Errorf("vdev %d = %+v, want %+v", i, vdevs[i], expected[i])
+		}
+	}
+}
+
+func TestParseJSON_Invalid(t *testing.T) {
+	for _, input := range []string{"", "zpool: invalid option 'j'", `{"output_version": {}}`} {
+		if _, err := ParseJSON([]byte(input)); err == nil {
+			t.Errorf("ParseJSON(%q) should fail", input)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package zpool
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	scanProgress = regexp.MustCompile(`([\d.]+)% done`)
+	scanErrors   = regexp.MustCompile(`with (\d+) errors`)
+)
+
+// entry is a line of the config section of zpool status
+type entry struct {
+	depth  int
+	fields []string
+}
+
+// ParseText returns the leaf vdevs of all pools in the text output of
+// zpool status, preferably run with -P -L so that vdevs are named by the
+// path of their device node
+func ParseText(output string) []Vdev {
+	var vdevs []Vdev
+	var pool, poolState string
+	var scanText []string
+	var entries []entry
+	inConfig, inScan := false, false
+	rootIndent := 0
+
+	flush := func() {
+		scan := parseScan(scanText)
+		for _, v := range configLeaves(pool, poolState, entries) {
+			v.Scan = scan
+			vdevs = append(vdevs, v)
+		}
+		entries = nil
+		scanText = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inScan {
+			// The scan section continues on tab-indented lines
+			if strings.HasPrefix(line, "\t") {
+				scanText = append(scanText, trimmed)
+				continue
+			}
+			inScan = false
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "pool:"):
+			flush()
+			pool = strings.TrimSpace(strings.TrimPrefix(trimmed, "pool:"))
+			poolState = ""
+			inConfig = false
+		case !inConfig && strings.HasPrefix(trimmed, "scan:"):
+			scanText = append(scanText, strings.TrimSpace(strings.TrimPrefix(trimmed, "scan:")))
+			inScan = true
+		case !inConfig && strings.HasPrefix(trimmed, "state:"):
+			poolState = strings.TrimSpace(strings.TrimPrefix(trimmed, "state:"))
+		case strings.HasPrefix(trimmed, "NAME") && strings.Contains(trimmed, "STATE"):
+			inConfig = true
+		case inConfig && trimmed == "":
+			inConfig = false
+		case inConfig:
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			if len(entries) == 0 {
+				rootIndent = indent
+			}
+			entries = append(entries, entry{
+				depth:  (indent - rootIndent) / 2,
+				fields: strings.Fields(trimmed),
+			})
+		}
+	}
+	flush()
+
+	return vdevs
+}
+
+// configLeaves turns the config tree of a pool into its leaf vdevs
+func configLeaves(pool, poolState string, entries []entry) []Vdev {
+	var vdevs []Vdev
+	for i, e := range entries {
+		// Skip the pool itself, class headers such as "logs" or "spares",
+		// and interior vdevs
+		if e.depth == 0 || len(e.fields) < 2 {
+			continue
+		}
+		if i+1 < len(entries) && entries[i+1].depth > e.depth {
+			continue
+		}
+
+		v := Vdev{
+			Pool:      pool,
+			PoolState: poolState,
+			Name:      e.fields[0],
+			Path:      e.fields[0],
+			State:     e.fields[1],
+		}
+		if len(e.fields) >= 5 {
+			v.Read = parseCount(e.fields[2])
+			v.Write = parseCount(e.fields[3])
+			v.Cksum = parseCount(e.fields[4])
+		}
+		for _, note := range e.fields[2:] {
+			if note == "(resilvering)" {
+				v.Resilvering = true
+			}
+		}
+
+		// The nearest shallower entry is the parent vdev, unless it is the
+		// pool or a class header
+		for j := i - 1; j >= 0; j-- {
+			if entries[j].depth < e.depth {
+				if entries[j].depth > 0 && len(entries[j].fields) >= 2 {
+					v.Parent = entries[j].fields[0]
+					v.ParentState = entries[j].fields[1]
+				}
+				break
+			}
+		}
+
+		vdevs = append(vdevs, v)
+	}
+	return vdevs
+}
+
+// parseScan parses the scan section of zpool status, such as
+// "scrub in progress since ..." followed by "0B repaired, 22.80% done, ..."
+func parseScan(lines []string) Scan {
+	if len(lines) == 0 {
+		return Scan{}
+	}
+
+	text := strings.Join(lines, " ")
+	scan := Scan{
+		Active:  strings.Contains(text, "in progress"),
+		Summary: lines[0],
+	}
+	switch {
+	case strings.Contains(text, "resilver"):
+		scan.Function = "resilver"
+	case strings.Contains(text, "scrub"):
+		scan.Function = "scrub"
+	}
+	if match := scanProgress.FindStringSubmatch(text); match != nil {
+		if percent, err := strconv.ParseFloat(match[1], 64); err == nil {
+			scan.Progress = percent / 100
+		}
+	}
+	if match := scanErrors.FindStringSubmatch(text); match != nil {
+		scan.Errors, _ = strconv.Atoi(match[1])
+	}
+	return scan
+}
@@ -0,0 +1,114 @@
+package zpool
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	vdevs := ParseText(string(readFixture(t, "status-degraded.txt")))
+
+	scrubbed := Scan{
+		Function: "scrub",
+		Summary:  "scrub repaired 0B in 01:02:03 with 0 errors on Sun Oct 11 01:26:04 2026",
+	}
+	expected := []Vdev{
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "raidz1-0", ParentState: "DEGRADED", Name: "/dev/sda1", Path: "/dev/sda1", State: "ONLINE", Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "raidz1-0", ParentState: "DEGRADED", Name: "/dev/sdb1", Path: "/dev/sdb1", State: "FAULTED", Read: 3, Write: 1200, Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "raidz1-0", ParentState: "DEGRADED", Name: "/dev/sdc1", Path: "/dev/sdc1", State: "ONLINE", Cksum: 2, Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "mirror-1", ParentState: "ONLINE", Name: "/dev/nvme0n1p3", Path: "/dev/nvme0n1p3", State: "ONLINE", Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Parent: "mirror-1", ParentState: "ONLINE", Name: "/dev/nvme1n1p3", Path: "/dev/nvme1n1p3", State: "ONLINE", Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Name: "/dev/nvme0n1p1", Path: "/dev/nvme0n1p1", State: "ONLINE", Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Name: "/dev/nvme1n1p1", Path: "/dev/nvme1n1p1", State: "ONLINE", Scan: scrubbed},
+		{Pool: "tank", PoolState: "DEGRADED", Name: "/dev/sdf1", Path: "/dev/sdf1", State: "AVAIL", Scan: scrubbed},
+		{Pool: "backup", PoolState: "ONLINE", Name: "/dev/sdg", Path: "/dev/sdg", State: "ONLINE"},
+	}
+
+	if len(vdevs) != len(expected) {
+		t.Fatalf("ParseText() returned %d vdevs, want %d: %+v", len(vdevs), len(expected), vdevs)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(vdevs[i], expected[i]) {
+			t.Errorf("vdev %d = %+v, want %+v", i, vdevs[i], expected[i])
+		}
+	}
+}
+
+func TestParseText_Resilver(t *testing.T) {
+	vdevs := ParseText(string(readFixture(t, "status-resilver.txt")))
+	if len(vdevs) != 3 {
+		t.Fatalf("ParseText() returned %d vdevs, want 3: %+v", len(vdevs), vdevs)
+	}
+
+	scan := Scan{Function: "resilver", Active: true, Progress: 0.25, Summary: "resilver in progress since Sun Oct 18 10:00:00 2026"}
+	for _, v := range vdevs {
+		if v.Scan != scan {
+			t.Errorf("%s scan = %+v, want %+v", v.Name, v.Scan, scan)
+		}
+	}
+	if old := vdevs[1]; old.Name != "4839211982734645123" || old.State != "UNAVAIL" || old.Parent != "replacing-1" || old.Resilvering {
+		t.Errorf("replaced vdev = %+v", old)
+	}
+	if v := vdevs[2]; v.Name != "/dev/sdd1" || !v.Resilvering || v.ParentState != "DEGRADED" {
+		t.Errorf("replacing vdev = %+v, want /dev/sdd1 resilvering", v)
+	}
+	if vdevs[0].Resilvering {
+		t.Errorf("%s should not be resilvering", vdevs[0].Name)
+	}
+}
+
+func TestParseText_ByID(t *testing.T) {
+	root := t.TempDir()
+	buildFakeSysfs(t, root, map[string][]string{"sda": nil, "sdb": {"sdb1"}})
+	symlink(t, "../../sda", filepath.Join(root, "dev", "disk", "by-id", "ata-WDC_WD40EFRX-68N32N0_WD-WCC7K1234567"))
+	symlink(t, "../../sdb1", filepath.Join(root, "dev", "disk", "by-id", "ata-ST4000VN008-2DR166_ZL2ABCDE-part1"))
+
+	vdevs := ParseText(string(readFixture(t, "status-byid.txt")))
+	Resolve(root, vdevs)
+
+	disks := []string{"sda", "sdb"}
+	if len(vdevs) != len(disks) {
+		t.Fatalf("ParseText() returned %d vdevs, want %d", len(vdevs), len(disks))
+	}
+	for i, disk := range disks {
+		if !reflect.DeepEqual(vdevs[i].Disks, []string{disk}) {
+			t.Errorf("%s disks = %v, want [%s]", vdevs[i].Name, vdevs[i].Disks, disk)
+		}
+		if !vdevs[i].Scan.Active || vdevs[i].Scan.Function != "scrub" || vdevs[i].Scan.Progress != 0.5 {
+			t.Errorf("%s scan = %+v, want scrub in progress at 50%%", vdevs[i].Name, vdevs[i].Scan)
+		}
+	}
+}
+
+func TestParseScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected Scan
+	}{
+		{name: "none", expected: Scan{}},
+		{
+			name:     "scrub in progress",
+			lines:    []string{"scrub in progress since Sun Oct 18 09:00:00 2026", "1.00T scanned at 1.00G/s, 500G issued at 500M/s, 1.00T total", "0B repaired, 50.00% done, 00:20:00 to go"},
+			expected: Scan{Function: "scrub", Active: true, Progress: 0.5, Summary: "scrub in progress since Sun Oct 18 09:00:00 2026"},
+		},
+		{
+			name:     "resilver finished",
+			lines:    []string{"resilvered 2.40T in 02:00:00 with 3 errors on Sun Oct 18 12:00:00 2026"},
+			expected: Scan{Function: "resilver", Errors: 3, Summary: "resilvered 2.40T in 02:00:00 with 3 errors on Sun Oct 18 12:00:00 2026"},
+		},
+		{
+			name:     "scrub canceled",
+			lines:    []string{"scrub canceled on Sun Oct 18 12:00:00 2026"},
+			expected: Scan{Function: "scrub", Summary: "scrub canceled on Sun Oct 18 12:00:00 2026"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := parseScan(tt.lines); result != tt.expected {
+				t.Errorf("parseScan() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
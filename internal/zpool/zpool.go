@@ -0,0 +1,125 @@
+// Package zpool reads the state of ZFS pools from zpool status and maps
+// their leaf vdevs to the physical disks behind them.
+package zpool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/blockdev"
+	"github.com/scottjab/nix-ugreen-leds-controller/internal/command"
+)
+
+// Scan is the scrub or resilver status of a pool
+type Scan struct {
+	Function string // "scrub" or "resilver", "" if the pool was never scanned
+	Active   bool
+	Progress float64 // 0 to 1
+	Errors   int
+	Summary  string
+}
+
+// Vdev is a leaf vdev of a pool, usually a disk or a partition
+type Vdev struct {
+	Pool        string
+	PoolState   string
+	Parent      string // parent vdev such as "mirror-0", "" for a top-level device
+	ParentState string
+	Name        string   // name reported by zpool status
+	Path        string   // device node, e.g. /dev/sda1
+	Disks       []string // physical disks below the device, e.g. [sda]
+	State       string
+	Read        uint64
+	Write       uint64
+	Cksum       uint64
+	Scan        Scan
+	Resilvering bool // the device is the target of a resilver
+}
+
+// Status runs zpool status and returns the leaf vdevs of all pools. It
+// prefers the JSON output of OpenZFS 2.3 and later and falls back to the
+// text output. root is the filesystem root used to resolve devices, empty
+// for the host.
+func Status(ctx context.Context, r command.Runner, root string) ([]Vdev, error) {
+	output, err := r.Output(ctx, "zpool", "status", "-j", "--json-int", "-P", "-L")
+	if command.IsTimeout(err) {
+		// A hung zpool would only hang again
+		return nil, fmt.Errorf("failed to run zpool status: %w", err)
+	}
+	var vdevs []Vdev
+	if err == nil {
+		vdevs, err = ParseJSON(output)
+	}
+	if err != nil {
+		output, err = r.Output(ctx, "zpool", "status", "-P", "-L")
+		if err != nil {
+			return nil, fmt.Errorf("failed to run zpool status: %w", err)
+		}
+		vdevs = ParseText(string(output))
+	}
+
+	Resolve(root, vdevs)
+	return vdevs, nil
+}
+
+// Capacity runs zpool list and returns the percentage of each pool's space
+// that is allocated
+func Capacity(ctx context.Context, r command.Runner) (map[string]int, error) {
+	output, err := r.Output(ctx, "zpool", "list", "-H", "-p", "-o", "name,capacity")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run zpool list: %w", err)
+	}
+	return ParseCapacity(string(output)), nil
+}
+
+// ParseCapacity parses the output of zpool list -H -o name,capacity, with
+// or without -p
+func ParseCapacity(output string) map[string]int {
+	capacity := make(map[string]int)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(fields[1], "%"))
+		if err != nil {
+			continue
+		}
+		capacity[fields[0]] = percent
+	}
+	return capacity
+}
+
+// Resolve fills in the physical disks of each vdev
+func Resolve(root string, vdevs []Vdev) {
+	for i := range vdevs {
+		vdevs[i].Disks = blockdev.PhysicalDisks(root, vdevs[i].Path)
+	}
+}
+
+// parseCount parses an error counter such as "0", "12" or "1.5K"
+func parseCount(s string) uint64 {
+	multiplier := 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			multiplier = 1e3
+		case 'M':
+			multiplier = 1e6
+		case 'G':
+			multiplier = 1e9
+		case 'T':
+			multiplier = 1e12
+		}
+		if multiplier != 1 {
+			s = s[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return uint64(f * multiplier)
+}
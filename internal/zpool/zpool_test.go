@@ -0,0 +1,72 @@
+package zpool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// buildFakeSysfs creates /sys/block entries for disks and their partitions
+// below root
+func buildFakeSysfs(t *testing.T, root string, disks map[string][]string) {
+	t.Helper()
+	for disk, partitions := range disks {
+		dir := filepath.Join(root, "sys", "block", disk)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		for i, part := range partitions {
+			path := filepath.Join(dir, part, "partition")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create %s: %v", path, err)
+			}
+			if err := os.WriteFile(path, []byte{byte('1' + i), '\n'}, 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+		}
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tests := map[string]uint64{
+		"0":    0,
+		"17":   17,
+		"1.2K": 1200,
+		"3M":   3000000,
+		"-":    0,
+		"":     0,
+	}
+	for input, expected := range tests {
+		if result := parseCount(input); result != expected {
+			t.Errorf("parseCount(%q) = %d, want %d", input, result, expected)
+		}
+	}
+}
+
+func TestParseCapacity(t *testing.T) {
+	output := "tank\t83\nbackup\t7%\nbroken\t-\n\n"
+	expected := map[string]int{"tank": 83, "backup": 7}
+	if result := ParseCapacity(output); !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseCapacity() = %v, want %v", result, expected)
+	}
+}
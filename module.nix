@@ -24,6 +24,10 @@ let
   # Helper function to format RGB color as string
   formatColor = color: "${toString color.r} ${toString color.g} ${toString color.b}";
 
+  # Helper function to format SMART attribute thresholds and command timeouts as "name:value ..."
+  formatThresholds =
+    thresholds: concatStringsSep " " (mapAttrsToList (id: value: "${id}:${toString value}") thresholds);
+
   # RGB color type
   rgbColor = types.submodule {
     options = {
@@ -61,6 +65,16 @@ in
       enable = mkEnableOption "Enable LED hardware probing service";
     };
 
+    commandTimeouts = mkOption {
+      type = types.attrsOf types.int;
+      default = { };
+      example = {
+        zpool = 60;
+        smartctl = 45;
+      };
+      description = "Seconds external tools such as smartctl, zpool, ip and ping may run before they are killed, overriding the built-in timeouts";
+    };
+
     # Note: ugreen-power-led script doesn't exist in v0.3
     # powerLed = {
     #   enable = mkEnableOption "Enable power LED service";
@@ -73,10 +87,30 @@ in
         type = types.enum [
           "ata"
           "hctl"
+          "nvme"
           "serial"
+          "wwn"
+          "by-id"
+          "by-path"
         ];
         default = "ata";
-        description = "Method for mapping disks to LEDs (ata, hctl, or serial)";
+        description = "Method for mapping disks to LEDs (ata, hctl, nvme, serial, wwn, by-id, or by-path)";
+      };
+
+      diskMapping = mkOption {
+        type = types.listOf types.str;
+        default = [ ];
+        example = [
+          "pci-0000:00:17.0-ata-1"
+          "pci-0000:00:17.0-ata-2"
+        ];
+        description = ''
+          Per-slot disk keys in LED order, required for the serial, wwn, by-id
+          and by-path mapping methods. Keys are disk serial numbers, WWNs
+          (0x5000...), or link names under /dev/disk/by-id or /dev/disk/by-path.
+          The nvme method takes NVMe controller names (nvme0) or PCI addresses
+          (0000:01:00.0) and defaults to nvme0 through nvme7.
+        '';
       };
 
       checkSmart = mkOption {
@@ -91,12 +125,153 @@ in
         description = "Polling rate for smartctl in seconds";
       };
 
+      checkSmartFailedInterval = mkOption {
+        type = types.int;
+        default = 3600;
+        description = "Polling rate for re-checking disks that failed SMART in seconds";
+      };
+
+      smartWarnThresholds = mkOption {
+        type = types.attrsOf types.int;
+        default = {
+          "5" = 1;
+          "187" = 1;
+          "197" = 1;
+          "198" = 1;
+          "199" = 100;
+        };
+        description = "SMART attribute IDs and the raw value at which a disk shows the SMART warning color";
+      };
+
+      smartFailThresholds = mkOption {
+        type = types.attrsOf types.int;
+        default = {
+          "5" = 100;
+          "197" = 50;
+          "198" = 50;
+        };
+        description = "SMART attribute IDs and the raw value at which a disk shows the SMART failure color";
+      };
+
+      smartDeltaThresholds = mkOption {
+        type = types.attrsOf types.int;
+        default = {
+          "5" = 1;
+          "187" = 1;
+          "197" = 1;
+          "198" = 1;
+          "199" = 10;
+        };
+        description = "SMART attribute IDs and the raw increase between two polls at which a disk shows the SMART warning color until the service restarts or the warning is acknowledged";
+      };
+
       ledRefreshInterval = mkOption {
         type = types.float;
         default = 0.1;
         description = "Refresh interval for disk LEDs in seconds";
       };
 
+      separateReadWrite = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Show reads and writes on healthy disks with their own colors and blink lengths";
+      };
+
+      showDiscardFlush = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Also show discards and flushes when separateReadWrite is enabled";
+      };
+
+      diskReadBlinkInterval = mkOption {
+        type = types.int;
+        default = 100;
+        description = "Blink length in milliseconds for reads and flushes when separateReadWrite is enabled";
+      };
+
+      diskWriteBlinkInterval = mkOption {
+        type = types.int;
+        default = 200;
+        description = "Blink length in milliseconds for writes and discards when separateReadWrite is enabled";
+      };
+
+      activityScale = mkOption {
+        type = types.enum [
+          "none"
+          "throughput"
+          "utilization"
+        ];
+        default = "none";
+        description = ''
+          Scale disk activity with how busy the disk is. throughput compares
+          the sectors transferred per second with activityScaleMaxThroughput,
+          utilization uses the share of time the disk spent doing I/O.
+        '';
+      };
+
+      activityScaleTarget = mkOption {
+        type = types.enum [
+          "brightness"
+          "blink"
+        ];
+        default = "brightness";
+        description = "Whether busier disks get brighter LEDs or blink faster";
+      };
+
+      activityScaleMaxThroughput = mkOption {
+        type = types.int;
+        default = 250;
+        description = "Throughput in MB/s at which a disk shows as saturated when activityScale is throughput";
+      };
+
+      checkLatency = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Show disks with the slow color when their I/O stalls or their average request latency is far above that of the other disks";
+      };
+
+      latencyFactor = mkOption {
+        type = types.float;
+        default = 5.0;
+        description = "How many times the median latency of the other disks a disk must reach to show as slow";
+      };
+
+      latencyMin = mkOption {
+        type = types.int;
+        default = 100;
+        description = "Average latency in milliseconds per request below which a disk never shows as slow";
+      };
+
+      stallTimeout = mkOption {
+        type = types.int;
+        default = 10;
+        description = "Seconds a disk may have I/O in flight without completing any before it shows as slow";
+      };
+
+      checkIOErrors = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Check the kernel I/O error counter and SCSI device state of each disk";
+      };
+
+      checkIOErrorsInterval = mkOption {
+        type = types.int;
+        default = 5;
+        description = "Polling rate for checking kernel I/O errors in seconds";
+      };
+
+      ioErrorFailThreshold = mkOption {
+        type = types.int;
+        default = 50;
+        description = "Number of kernel I/O errors since the service started at which a disk shows the I/O failure color instead of the warning color";
+      };
+
+      ioErrorQuietPeriod = mkOption {
+        type = types.int;
+        default = 3600;
+        description = "Seconds without new kernel I/O errors after which a disk's earlier errors are forgotten, 0 to keep them until the service restarts or they are acknowledged";
+      };
+
       checkZpool = mkOption {
         type = types.bool;
         default = true;
@@ -115,12 +290,167 @@ in
         description = "Enable debug logging for ZFS pool checks";
       };
 
+      checkMdraid = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Check Linux md RAID health from /proc/mdstat and sysfs. Member disks use the ZFS pool colors";
+      };
+
+      checkMdraidInterval = mkOption {
+        type = types.int;
+        default = 5;
+        description = "Polling rate for checking md RAID health in seconds";
+      };
+
+      checkBtrfs = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Check btrfs device error counters and missing devices. Member disks use the ZFS pool colors: write and flush errors since the service started show as a failure, other errors as a warning";
+      };
+
+      checkBtrfsInterval = mkOption {
+        type = types.int;
+        default = 60;
+        description = "Polling rate for checking btrfs health in seconds";
+      };
+
+      checkTemperature = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Shift the color of healthy disks as they get hot. Temperatures come from drivetemp or NVMe hwmon sensors, falling back to smartctl. Disks in standby are never woken up";
+      };
+
+      checkTemperatureInterval = mkOption {
+        type = types.int;
+        default = 60;
+        description = "Polling rate for disk temperatures in seconds";
+      };
+
+      temperatureWarn = mkOption {
+        type = types.int;
+        default = 45;
+        description = "Temperature in degrees Celsius above which the healthy color shifts towards the hot color";
+      };
+
+      temperatureCrit = mkOption {
+        type = types.int;
+        default = 55;
+        description = "Temperature in degrees Celsius at which a disk blinks the critical temperature color";
+      };
+
+      temperatureCritBlinkInterval = mkOption {
+        type = types.int;
+        default = 250;
+        description = "Blink interval in milliseconds for disks at a critical temperature";
+      };
+
+      checkCapacity = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Show how full each disk is in the color of healthy disks. Usage comes from the mounted filesystems on the disk and the capacity of its zpools";
+      };
+
+      checkCapacityInterval = mkOption {
+        type = types.int;
+        default = 300;
+        description = "Capacity check interval in seconds";
+      };
+
+      capacityWarn = mkOption {
+        type = types.int;
+        default = 80;
+        description = "Percent used where healthy disks show colorCapacityWarn";
+      };
+
+      capacityCrit = mkOption {
+        type = types.int;
+        default = 90;
+        description = "Percent used where healthy disks show colorCapacityCrit";
+      };
+
       checkDiskOnlineInterval = mkOption {
         type = types.int;
         default = 5;
         description = "Polling rate for checking disk online status in seconds";
       };
 
+      hotplugRescanInterval = mkOption {
+        type = types.int;
+        default = 30;
+        description = "Polling rate for re-enumerating disks in seconds, used when kernel uevents are unavailable";
+      };
+
+      controlSocket = mkOption {
+        type = types.str;
+        default = "/run/ugreen-leds/control.sock";
+        description = ''
+          Unix socket the service accepts identify commands on, such as
+          `ugreen-leds-service -identify sda`. An empty string disables it.
+        '';
+      };
+
+      identifyTimeout = mkOption {
+        type = types.int;
+        default = 300;
+        description = "Seconds a bay blinks in the identify color unless cleared earlier";
+      };
+
+      identifyBlinkInterval = mkOption {
+        type = types.int;
+        default = 100;
+        description = "Blink interval in milliseconds for bays being identified";
+      };
+
+      stateFile = mkOption {
+        type = types.str;
+        default = "/var/lib/ugreen-leds/state.json";
+        description = ''
+          JSON file keeping the fault history of each disk by serial number.
+          Latched faults survive restarts until they are acknowledged. An
+          empty string disables it.
+        '';
+      };
+
+      latchFaults = mkOption {
+        type = types.listOf (
+          types.enum [
+            "smart"
+            "zpool"
+            "zpool-errors"
+            "md"
+            "btrfs"
+            "btrfs-errors"
+            "io"
+            "offline"
+          ]
+        );
+        default = [ ];
+        example = [
+          "smart"
+          "zpool"
+        ];
+        description = ''
+          Faults that keep showing after the disk recovers, until they are
+          acknowledged with `ugreen-leds-service -ack`. With a state file they
+          also survive restarts. None are latched by default.
+        '';
+      };
+
+      latchPattern = mkOption {
+        type = types.enum [
+          "fault"
+          "dim"
+        ];
+        default = "fault";
+        description = "Show a latched fault as the fault itself, or dimmed once the disk recovered";
+      };
+
+      latchedBrightness = mkOption {
+        type = types.int;
+        default = 64;
+        description = "Brightness of latched faults with the dim pattern (0-255)";
+      };
+
       colorDiskHealth = mkOption {
         type = rgbColor;
         default = {
@@ -151,6 +481,56 @@ in
         description = "Color for disks in standby (RGB)";
       };
 
+      colorDiskRead = mkOption {
+        type = rgbColor;
+        default = {
+          r = 0;
+          g = 255;
+          b = 0;
+        };
+        description = "Color for healthy disks while reading, when separateReadWrite is enabled (RGB)";
+      };
+
+      colorDiskWrite = mkOption {
+        type = rgbColor;
+        default = {
+          r = 0;
+          g = 128;
+          b = 255;
+        };
+        description = "Color for healthy disks while writing, when separateReadWrite is enabled (RGB)";
+      };
+
+      colorDiskDiscard = mkOption {
+        type = rgbColor;
+        default = {
+          r = 128;
+          g = 0;
+          b = 255;
+        };
+        description = "Color for healthy disks while discarding, when showDiscardFlush is enabled (RGB)";
+      };
+
+      colorDiskFlush = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 255;
+          b = 128;
+        };
+        description = "Color for healthy disks while flushing their cache, when showDiscardFlush is enabled (RGB)";
+      };
+
+      colorDiskSlow = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 0;
+          b = 128;
+        };
+        description = "Color for slow or stalled disks, when checkLatency is enabled (RGB)";
+      };
+
       colorZpoolFail = mkOption {
         type = rgbColor;
         default = {
@@ -161,6 +541,68 @@ in
         description = "Color for failed ZFS pools (RGB)";
       };
 
+      colorZpoolPoolDegraded = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 255;
+          b = 0;
+        };
+        description = "Color for members of a degraded ZFS pool (RGB)";
+      };
+
+      colorZpoolVdevDegraded = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 128;
+          b = 0;
+        };
+        description = "Color for members of a degraded ZFS vdev (RGB)";
+      };
+
+      colorZpoolErrors = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 64;
+          b = 0;
+        };
+        description = "Color for ZFS pool disks with read, write or checksum errors (RGB)";
+      };
+
+      zpoolErrorThreshold = mkOption {
+        type = types.int;
+        default = 1;
+        description = "Number of read, write or checksum errors reported by zpool status before a disk shows the error color";
+      };
+
+      colorZpoolScrub = mkOption {
+        type = rgbColor;
+        default = {
+          r = 0;
+          g = 255;
+          b = 255;
+        };
+        description = "Color for disks of a ZFS pool being scrubbed (RGB)";
+      };
+
+      colorZpoolResilver = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 0;
+          b = 255;
+        };
+        description = "Color for ZFS disks being resilvered (RGB)";
+      };
+
+      zpoolScanBlinkInterval = mkOption {
+        type = types.int;
+        default = 1000;
+        description = "Blink interval in milliseconds while a scrub or resilver is running. Brightness rises with its progress";
+      };
+
       colorSmartFail = mkOption {
         type = rgbColor;
         default = {
@@ -171,16 +613,116 @@ in
         description = "Color for SMART failures (RGB)";
       };
 
+      colorSmartWarn = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 192;
+          b = 0;
+        };
+        description = "Color for SMART warnings from attribute thresholds (RGB)";
+      };
+
+      colorTemperatureHot = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 0;
+          b = 0;
+        };
+        description = "Color healthy disks shift to as they approach the critical temperature (RGB)";
+      };
+
+      colorTemperatureCrit = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 0;
+          b = 0;
+        };
+        description = "Blinking color for disks at a critical temperature (RGB)";
+      };
+
+      colorCapacityWarn = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 255;
+          b = 0;
+        };
+        description = "Color for healthy disks above the capacity warning threshold (RGB)";
+      };
+
+      colorCapacityCrit = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 128;
+          b = 0;
+        };
+        description = "Color for healthy disks above the critical capacity threshold (RGB)";
+      };
+
+      colorIdentify = mkOption {
+        type = rgbColor;
+        default = {
+          r = 0;
+          g = 255;
+          b = 0;
+        };
+        description = "Blinking color for bays being identified (RGB)";
+      };
+
+      colorCommandTimeout = mkOption {
+        type = rgbColor;
+        default = {
+          r = 128;
+          g = 128;
+          b = 128;
+        };
+        description = "Color for disks whose checks timed out, so their health is unknown (RGB)";
+      };
+
+      colorIOErrorWarn = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 96;
+          b = 0;
+        };
+        description = "Color for disks with new kernel I/O errors or a blocked SCSI device (RGB)";
+      };
+
+      colorIOErrorFail = mkOption {
+        type = rgbColor;
+        default = {
+          r = 255;
+          g = 0;
+          b = 0;
+        };
+        description = "Color for disks past the I/O error threshold or with an offline SCSI device (RGB)";
+      };
+
       brightnessDiskLeds = mkOption {
         type = types.int;
         default = 255;
         description = "Brightness for disk LEDs (0-255)";
       };
 
+      checkStandby = mkOption {
+        type = types.bool;
+        default = false;
+        description = "Show disks in standby with the standby color. This asks every disk for its power mode at each check";
+      };
+
       standbyMonPath = mkOption {
         type = types.str;
         default = "/usr/bin/ugreen-check-standby";
-        description = "Path to standby monitoring binary";
+        description = ''
+          Path to a standby helper, used for disks that do not answer ATA
+          CHECK POWER MODE. It is called with the device path and should print
+          the drive state like `hdparm -C`.
+        '';
       };
 
       standbyCheckInterval = mkOption {
@@ -226,6 +768,16 @@ in
         description = "Color when gateway is unreachable (RGB)";
       };
 
+      colorCommandTimeout = mkOption {
+        type = rgbColor;
+        default = {
+          r = 128;
+          g = 128;
+          b = 128;
+        };
+        description = "Color when `ip route` or the gateway ping timed out, so whether the gateway is reachable is unknown (RGB)";
+      };
+
       colorLinkPurpleDefault = mkOption {
         type = rgbColor;
         default = {
@@ -358,22 +910,87 @@ in
     let
       # Generate config file content
       configFileContent = ''
+        COMMAND_TIMEOUTS="${formatThresholds cfg.commandTimeouts}"
+
         # Disk Monitor Configuration
         DISK_MONITOR_ENABLE=${if cfg.diskMonitor.enable then "true" else "false"}
         MAPPING_METHOD=${cfg.diskMonitor.mappingMethod}
+        DISK_MAPPING="${lib.concatStringsSep " " cfg.diskMonitor.diskMapping}"
         CHECK_SMART=${if cfg.diskMonitor.checkSmart then "true" else "false"}
         CHECK_SMART_INTERVAL=${toString cfg.diskMonitor.checkSmartInterval}
+        CHECK_SMART_FAILED_INTERVAL=${toString cfg.diskMonitor.checkSmartFailedInterval}
+        SMART_WARN_THRESHOLDS="${formatThresholds cfg.diskMonitor.smartWarnThresholds}"
+        SMART_FAIL_THRESHOLDS="${formatThresholds cfg.diskMonitor.smartFailThresholds}"
+        SMART_DELTA_THRESHOLDS="${formatThresholds cfg.diskMonitor.smartDeltaThresholds}"
         LED_REFRESH_INTERVAL=${toString cfg.diskMonitor.ledRefreshInterval}
+        SEPARATE_READ_WRITE=${if cfg.diskMonitor.separateReadWrite then "true" else "false"}
+        SHOW_DISCARD_FLUSH=${if cfg.diskMonitor.showDiscardFlush then "true" else "false"}
+        DISK_READ_BLINK_INTERVAL=${toString cfg.diskMonitor.diskReadBlinkInterval}
+        DISK_WRITE_BLINK_INTERVAL=${toString cfg.diskMonitor.diskWriteBlinkInterval}
+        ACTIVITY_SCALE=${cfg.diskMonitor.activityScale}
+        ACTIVITY_SCALE_TARGET=${cfg.diskMonitor.activityScaleTarget}
+        ACTIVITY_SCALE_MAX_THROUGHPUT=${toString cfg.diskMonitor.activityScaleMaxThroughput}
+        CHECK_LATENCY=${if cfg.diskMonitor.checkLatency then "true" else "false"}
+        LATENCY_FACTOR=${toString cfg.diskMonitor.latencyFactor}
+        LATENCY_MIN=${toString cfg.diskMonitor.latencyMin}
+        STALL_TIMEOUT=${toString cfg.diskMonitor.stallTimeout}
+        CHECK_IO_ERRORS=${if cfg.diskMonitor.checkIOErrors then "true" else "false"}
+        CHECK_IO_ERRORS_INTERVAL=${toString cfg.diskMonitor.checkIOErrorsInterval}
+        IO_ERROR_FAIL_THRESHOLD=${toString cfg.diskMonitor.ioErrorFailThreshold}
+        IO_ERROR_QUIET_PERIOD=${toString cfg.diskMonitor.ioErrorQuietPeriod}
         CHECK_ZPOOL=${if cfg.diskMonitor.checkZpool then "true" else "false"}
         CHECK_ZPOOL_INTERVAL=${toString cfg.diskMonitor.checkZpoolInterval}
         DEBUG_ZPOOL=${if cfg.diskMonitor.debugZpool then "true" else "false"}
+        CHECK_MDRAID=${if cfg.diskMonitor.checkMdraid then "true" else "false"}
+        CHECK_MDRAID_INTERVAL=${toString cfg.diskMonitor.checkMdraidInterval}
+        CHECK_BTRFS=${if cfg.diskMonitor.checkBtrfs then "true" else "false"}
+        CHECK_BTRFS_INTERVAL=${toString cfg.diskMonitor.checkBtrfsInterval}
+        CHECK_TEMPERATURE=${if cfg.diskMonitor.checkTemperature then "true" else "false"}
+        CHECK_TEMPERATURE_INTERVAL=${toString cfg.diskMonitor.checkTemperatureInterval}
+        TEMPERATURE_WARN=${toString cfg.diskMonitor.temperatureWarn}
+        TEMPERATURE_CRIT=${toString cfg.diskMonitor.temperatureCrit}
+        TEMPERATURE_CRIT_BLINK_INTERVAL=${toString cfg.diskMonitor.temperatureCritBlinkInterval}
+        CHECK_CAPACITY=${if cfg.diskMonitor.checkCapacity then "true" else "false"}
+        CHECK_CAPACITY_INTERVAL=${toString cfg.diskMonitor.checkCapacityInterval}
+        CAPACITY_WARN=${toString cfg.diskMonitor.capacityWarn}
+        CAPACITY_CRIT=${toString cfg.diskMonitor.capacityCrit}
         CHECK_DISK_ONLINE_INTERVAL=${toString cfg.diskMonitor.checkDiskOnlineInterval}
+        HOTPLUG_RESCAN_INTERVAL=${toString cfg.diskMonitor.hotplugRescanInterval}
+        CONTROL_SOCKET="${cfg.diskMonitor.controlSocket}"
+        IDENTIFY_TIMEOUT=${toString cfg.diskMonitor.identifyTimeout}
+        IDENTIFY_BLINK_INTERVAL=${toString cfg.diskMonitor.identifyBlinkInterval}
+        STATE_FILE="${cfg.diskMonitor.stateFile}"
+        LATCH_FAULTS="${lib.concatStringsSep " " cfg.diskMonitor.latchFaults}"
+        LATCH_PATTERN=${cfg.diskMonitor.latchPattern}
+        LATCHED_BRIGHTNESS=${toString cfg.diskMonitor.latchedBrightness}
         COLOR_DISK_HEALTH="${formatColor cfg.diskMonitor.colorDiskHealth}"
         COLOR_DISK_UNAVAIL="${formatColor cfg.diskMonitor.colorDiskUnavail}"
         COLOR_DISK_STANDBY="${formatColor cfg.diskMonitor.colorDiskStandby}"
+        COLOR_DISK_READ="${formatColor cfg.diskMonitor.colorDiskRead}"
+        COLOR_DISK_WRITE="${formatColor cfg.diskMonitor.colorDiskWrite}"
+        COLOR_DISK_DISCARD="${formatColor cfg.diskMonitor.colorDiskDiscard}"
+        COLOR_DISK_FLUSH="${formatColor cfg.diskMonitor.colorDiskFlush}"
+        COLOR_DISK_SLOW="${formatColor cfg.diskMonitor.colorDiskSlow}"
         COLOR_ZPOOL_FAIL="${formatColor cfg.diskMonitor.colorZpoolFail}"
+        COLOR_ZPOOL_POOL_DEGRADED="${formatColor cfg.diskMonitor.colorZpoolPoolDegraded}"
+        COLOR_ZPOOL_VDEV_DEGRADED="${formatColor cfg.diskMonitor.colorZpoolVdevDegraded}"
+        COLOR_ZPOOL_ERRORS="${formatColor cfg.diskMonitor.colorZpoolErrors}"
+        ZPOOL_ERROR_THRESHOLD=${toString cfg.diskMonitor.zpoolErrorThreshold}
+        COLOR_ZPOOL_SCRUB="${formatColor cfg.diskMonitor.colorZpoolScrub}"
+        COLOR_ZPOOL_RESILVER="${formatColor cfg.diskMonitor.colorZpoolResilver}"
+        ZPOOL_SCAN_BLINK_INTERVAL=${toString cfg.diskMonitor.zpoolScanBlinkInterval}
         COLOR_SMART_FAIL="${formatColor cfg.diskMonitor.colorSmartFail}"
+        COLOR_SMART_WARN="${formatColor cfg.diskMonitor.colorSmartWarn}"
+        COLOR_IO_ERROR_WARN="${formatColor cfg.diskMonitor.colorIOErrorWarn}"
+        COLOR_IO_ERROR_FAIL="${formatColor cfg.diskMonitor.colorIOErrorFail}"
+        COLOR_TEMPERATURE_HOT="${formatColor cfg.diskMonitor.colorTemperatureHot}"
+        COLOR_TEMPERATURE_CRIT="${formatColor cfg.diskMonitor.colorTemperatureCrit}"
+        COLOR_CAPACITY_WARN="${formatColor cfg.diskMonitor.colorCapacityWarn}"
+        COLOR_CAPACITY_CRIT="${formatColor cfg.diskMonitor.colorCapacityCrit}"
+        COLOR_IDENTIFY="${formatColor cfg.diskMonitor.colorIdentify}"
+        COLOR_COMMAND_TIMEOUT="${formatColor cfg.diskMonitor.colorCommandTimeout}"
         BRIGHTNESS_DISK_LEDS=${toString cfg.diskMonitor.brightnessDiskLeds}
+        CHECK_STANDBY=${if cfg.diskMonitor.checkStandby then "true" else "false"}
         STANDBY_MON_PATH=${cfg.diskMonitor.standbyMonPath}
         STANDBY_CHECK_INTERVAL=${toString cfg.diskMonitor.standbyCheckInterval}
         BLINK_MON_PATH=${cfg.diskMonitor.blinkMonPath}
@@ -382,6 +999,7 @@ in
         NETWORK_INTERFACES="${lib.concatStringsSep " " cfg.networkMonitor.interfaces}"
         COLOR_NETDEV_NORMAL="${formatColor cfg.networkMonitor.colorNormal}"
         COLOR_NETDEV_GATEWAY_UNREACHABLE="${formatColor cfg.networkMonitor.colorGatewayUnreachable}"
+        COLOR_NETDEV_COMMAND_TIMEOUT="${formatColor cfg.networkMonitor.colorCommandTimeout}"
         COLOR_NETDEV_LINK_PURPLE_DEFAULT="${formatColor cfg.networkMonitor.colorLinkPurpleDefault}"
         ${optionalString (
           cfg.networkMonitor.colorLink100 != null
@@ -418,12 +1036,17 @@ in
       '';
     in
     {
-      boot.kernelModules = mkIf cfg.kernelModule.enable [
-        "i2c-dev"
-        "led-ugreen"
-        "ledtrig-oneshot"
-        "ledtrig-netdev"
-      ];
+      boot.kernelModules = mkIf cfg.kernelModule.enable (
+        [
+          "i2c-dev"
+          "led-ugreen"
+          "ledtrig-oneshot"
+          "ledtrig-netdev"
+          "ledtrig-timer"
+        ]
+        # hwmon temperature sensors for SATA disks
+        ++ optional cfg.diskMonitor.checkTemperature "drivetemp"
+      );
 
       boot.extraModulePackages = mkIf cfg.kernelModule.enable [
         package.kernelModule
@@ -462,6 +1085,9 @@ in
             wants = [ "ugreen-probe-leds.service" ];
             serviceConfig = {
               ExecStart = "${package}/bin/ugreen-leds-service -config /etc/ugreen-leds.conf";
+              # Hold the default control socket and fault history
+              RuntimeDirectory = "ugreen-leds";
+              StateDirectory = "ugreen-leds";
               StandardOutput = "journal";
               StandardError = "journal";
               Restart = "on-failure";
@@ -471,6 +1097,8 @@ in
                 "PATH=${
                   lib.makeBinPath [
                     pkgs.smartmontools
+                    pkgs.nvme-cli
+                    pkgs.btrfs-progs
                     pkgs.zfs
                     pkgs.iproute2
                     pkgs.util-linux